@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/AlexMickh/proj-user/internal/app"
+	"github.com/AlexMickh/proj-user/internal/config"
+	"github.com/AlexMickh/proj-user/internal/consts"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/service"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/internal/validation"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+	"go.uber.org/zap"
+)
+
+type options struct {
+	file      string
+	format    string
+	avatars   string
+	report    string
+	batchSize int
+	provider  string
+	verified  bool
+}
+
+func main() {
+	var opts options
+	flag.StringVar(&opts.file, "file", "", "path to the csv or jsonl file with users")
+	flag.StringVar(&opts.format, "format", "", "csv or jsonl, guessed from the file extension by default")
+	flag.StringVar(&opts.avatars, "avatars", "", "directory the avatar file names are resolved in")
+	flag.StringVar(&opts.report, "report", "import-errors.csv", "path to the report of rejected rows")
+	flag.IntVar(&opts.batchSize, "batch", 200, "number of users inserted at once")
+	flag.StringVar(&opts.provider, "provider", consts.FieldProvider, "provider the users signed up with, only its users may have no password")
+	flag.BoolVar(&opts.verified, "verified", false, "mark the emails as verified")
+
+	cfg := config.MustLoad()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	ctx = logger.New(ctx, []string{"stdout"}, cfg.Env)
+	log := logger.FromCtx(ctx)
+
+	if opts.file == "" {
+		log.Fatal("file is required")
+	}
+	if opts.batchSize <= 0 {
+		log.Fatal("batch must be positive", zap.Int("batch", opts.batchSize))
+	}
+	if opts.format == "" {
+		opts.format = strings.TrimPrefix(strings.ToLower(filepath.Ext(opts.file)), ".")
+	}
+
+	deps := app.NewDeps(ctx, cfg)
+	defer deps.Close()
+
+	imported, rejected, err := run(ctx, deps.Service, opts)
+	log.Info("import finished", zap.Int("imported", imported), zap.Int("rejected", rejected))
+	if err != nil {
+		log.Error("import failed", zap.Error(err))
+		deps.Close()
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, service *service.Service, opts options) (int, int, error) {
+	in, err := os.Open(opts.file)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	var src source
+	switch opts.format {
+	case "csv":
+		src, err = newCSVSource(in)
+	case "jsonl":
+		src = newJSONLSource(in)
+	default:
+		err = fmt.Errorf("unsupported format %q", opts.format)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var avatars fs.FS
+	if opts.avatars != "" {
+		avatars = os.DirFS(opts.avatars)
+	}
+
+	out, err := os.Create(opts.report)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = out.Close()
+	}()
+
+	// the report matters most when the import is aborted
+	report := csv.NewWriter(out)
+	defer report.Flush()
+	if err := report.Write([]string{"line", "email", "error"}); err != nil {
+		return 0, 0, err
+	}
+
+	var imported, rejected int
+	reject := func(rec record, err error) error {
+		rejected++
+		return report.Write([]string{strconv.Itoa(rec.line), rec.user.Email, reason(err)})
+	}
+
+	batch := make([]record, 0, opts.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		users := make([]models.NewUser, 0, len(batch))
+		for _, rec := range batch {
+			users = append(users, rec.user)
+		}
+
+		errs, err := service.ImportUsers(ctx, opts.provider, opts.verified, users)
+		if err != nil {
+			return err
+		}
+
+		for i, err := range errs {
+			if err == nil {
+				imported++
+				continue
+			}
+			if err := reject(batch[i], err); err != nil {
+				return err
+			}
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return imported, rejected, err
+		}
+
+		rec, err := src.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return imported, rejected, err
+		}
+
+		if rec.err == nil {
+			rec.err = validate(opts.provider, rec.user)
+		}
+		if rec.err == nil && rec.avatar != "" {
+			rec.user.Avatar, rec.err = readAvatar(avatars, rec.avatar)
+		}
+		if rec.err != nil {
+			if err := reject(rec, rec.err); err != nil {
+				return imported, rejected, err
+			}
+			continue
+		}
+
+		batch = append(batch, rec)
+		if len(batch) == opts.batchSize {
+			if err := flush(); err != nil {
+				return imported, rejected, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return imported, rejected, err
+	}
+
+	report.Flush()
+	return imported, rejected, report.Error()
+}
+
+// validate checks a row. Users of other providers sign in through them, so
+// the password is optional, but one that is set must still be valid.
+func validate(provider string, user models.NewUser) error {
+	if provider == consts.FieldProvider || user.Password != "" {
+		return validation.NewUser(user.Email, user.Name, user.Password, user.Skills)
+	}
+
+	return validation.ProviderUser(user.Email, user.Name, user.Skills)
+}
+
+func readAvatar(avatars fs.FS, name string) ([]byte, error) {
+	if avatars == nil {
+		return nil, errors.New("avatar is set but the avatars directory is not")
+	}
+
+	data, err := fs.ReadFile(avatars, filepath.ToSlash(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read avatar: %w", err)
+	}
+
+	return data, nil
+}
+
+// reason returns the message of the known error err wraps, so the report
+// doesn't leak the call chain.
+func reason(err error) string {
+	known := []error{
+		storage.ErrUserAlreadyExists,
+		storage.ErrInvalidSkills,
+		service.ErrAvatarTooLarge,
+		service.ErrInvalidAvatar,
+		mailaddr.ErrInvalid,
+		mailaddr.ErrTooLong,
+	}
+	for _, k := range known {
+		if errors.Is(err, k) {
+			return k.Error()
+		}
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+)
+
+// record is a user read from the input file. err is set when the row
+// couldn't be parsed, the row is reported and the import goes on.
+type record struct {
+	line   int
+	user   models.NewUser
+	avatar string
+	err    error
+}
+
+type source interface {
+	// next returns the next record or io.EOF when the input is over.
+	next() (record, error)
+}
+
+// csvSource reads users from a csv file with a header. Skills are separated
+// by ";" and written as "slug[:level[:years]]".
+type csvSource struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVSource(r io.Reader) (*csvSource, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	// columns are looked up by the header, missing trailing ones are empty
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"email", "name", "password", "skills"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("column %s is missing", name)
+		}
+	}
+
+	return &csvSource{
+		r:       reader,
+		columns: columns,
+	}, nil
+}
+
+func (s *csvSource) next() (record, error) {
+	row, err := s.r.Read()
+	if errors.Is(err, io.EOF) {
+		return record{}, io.EOF
+	}
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return record{line: parseErr.Line, err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return record{}, err
+	}
+
+	line, _ := s.r.FieldPos(0)
+	rec := record{line: line}
+
+	rec.user = models.NewUser{
+		Email:    s.field(row, "email"),
+		Name:     s.field(row, "name"),
+		Password: s.field(row, "password"),
+		About:    s.field(row, "about"),
+	}
+	rec.avatar = s.field(row, "avatar")
+	rec.user.Skills, rec.err = parseSkills(s.field(row, "skills"))
+
+	return rec, nil
+}
+
+func (s *csvSource) field(row []string, name string) string {
+	i, ok := s.columns[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+
+	return strings.TrimSpace(row[i])
+}
+
+func parseSkills(value string) ([]models.UserSkill, error) {
+	var skills []models.UserSkill
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := strings.Split(part, ":")
+		if len(fields) > 3 {
+			return nil, fmt.Errorf("invalid skill %q", part)
+		}
+
+		skill := models.UserSkill{Slug: fields[0]}
+		var err error
+		if len(fields) > 1 {
+			if skill.Level, err = strconv.Atoi(fields[1]); err != nil {
+				return nil, fmt.Errorf("invalid level of skill %s", skill.Slug)
+			}
+		}
+		if len(fields) > 2 {
+			if skill.Years, err = strconv.Atoi(fields[2]); err != nil {
+				return nil, fmt.Errorf("invalid years of skill %s", skill.Slug)
+			}
+		}
+		skills = append(skills, skill)
+	}
+
+	return skills, nil
+}
+
+// jsonlSource reads users from a file with a json object per line.
+type jsonlSource struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+type jsonlUser struct {
+	Email    string             `json:"email"`
+	Name     string             `json:"name"`
+	Password string             `json:"password"`
+	About    string             `json:"about"`
+	Skills   []models.UserSkill `json:"skills"`
+	Avatar   string             `json:"avatar"`
+}
+
+const maxJSONLLine = 1 << 20
+
+func newJSONLSource(r io.Reader) *jsonlSource {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxJSONLLine)
+
+	return &jsonlSource{
+		scanner: scanner,
+	}
+}
+
+func (s *jsonlSource) next() (record, error) {
+	for s.scanner.Scan() {
+		s.line++
+
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		rec := record{line: s.line}
+
+		var user jsonlUser
+		if err := json.Unmarshal([]byte(line), &user); err != nil {
+			rec.err = fmt.Errorf("invalid json: %w", err)
+			return rec, nil
+		}
+
+		rec.user = models.NewUser{
+			Email:    strings.TrimSpace(user.Email),
+			Name:     strings.TrimSpace(user.Name),
+			Password: user.Password,
+			About:    user.About,
+			Skills:   user.Skills,
+		}
+		rec.avatar = strings.TrimSpace(user.Avatar)
+
+		return rec, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return record{}, err
+	}
+
+	return record{}, io.EOF
+}
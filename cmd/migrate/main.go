@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/AlexMickh/proj-user/internal/config"
+	"github.com/AlexMickh/proj-user/migrations"
+	"github.com/AlexMickh/proj-user/pkg/postgres_client"
+	"github.com/golang-migrate/migrate/v4"
+)
+
+const usageText = `usage: migrate [-config path] <command> [args]
+
+commands:
+  up [n]         apply all or n pending migrations
+  down <n|all>   roll back n or all applied migrations
+  goto <v>       migrate up or down to version v
+  version        print the current version
+  force <v>      set the version without running migrations, clears the dirty flag
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		fmt.Fprintln(flag.CommandLine.Output(), "\nflags:")
+		flag.PrintDefaults()
+	}
+
+	cfg := config.MustLoad()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m, err := postgres_client.NewMigrate(
+		cfg.DB.User,
+		cfg.DB.Password,
+		cfg.DB.Host,
+		cfg.DB.Port,
+		cfg.DB.Name,
+		migrations.Source(cfg.DB.MigrationsPath),
+	)
+	if err != nil {
+		fail(err)
+	}
+	m.Log = logger{}
+
+	err = run(m, args[0], args[1:])
+	_, _ = m.Close()
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func run(m *migrate.Migrate, cmd string, args []string) error {
+	switch cmd {
+	case "up":
+		if len(args) == 0 {
+			return noChange(m.Up())
+		}
+		n, err := steps(args)
+		if err != nil {
+			return err
+		}
+		return noChange(m.Steps(n))
+	case "down":
+		if len(args) == 1 && args[0] == "all" {
+			return noChange(m.Down())
+		}
+		// rolling everything back needs an explicit "all"
+		n, err := steps(args)
+		if err != nil {
+			return err
+		}
+		return noChange(m.Steps(-n))
+	case "goto":
+		v, err := version(args)
+		if err != nil {
+			return err
+		}
+		return noChange(m.Migrate(uint(v)))
+	case "force":
+		v, err := version(args)
+		if err != nil {
+			return err
+		}
+		return m.Force(v)
+	case "version":
+		if len(args) != 0 {
+			return errUsage
+		}
+		return printVersion(m)
+	default:
+		return errUsage
+	}
+}
+
+func printVersion(m *migrate.Migrate) error {
+	v, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Println("no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if dirty {
+		fmt.Printf("%d (dirty)\n", v)
+	} else {
+		fmt.Println(v)
+	}
+
+	return nil
+}
+
+func steps(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+	}
+
+	return n, nil
+}
+
+func version(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errUsage
+	}
+
+	v, err := strconv.Atoi(args[0])
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid version %q", args[0])
+	}
+
+	return v, nil
+}
+
+func noChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		fmt.Println("no change")
+		return nil
+	}
+
+	return err
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate: "+err.Error())
+	os.Exit(1)
+}
+
+// logger prints the migrations as they are applied.
+type logger struct{}
+
+func (logger) Printf(format string, v ...any) {
+	fmt.Fprintf(os.Stderr, format, v...)
+}
+
+func (logger) Verbose() bool {
+	return false
+}
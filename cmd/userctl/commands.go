@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/service"
+)
+
+const maxFindLimit = 1000
+
+func find(ctx context.Context, service *service.Service, out printer, args []string) error {
+	fs := flag.NewFlagSet("find", flag.ContinueOnError)
+	limit := fs.Int("limit", 20, "maximum number of users to print")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *limit <= 0 || *limit > maxFindLimit {
+		return fmt.Errorf("limit must be between 1 and %d", maxFindLimit)
+	}
+
+	search, err := oneArg(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	users, err := service.FindUsers(ctx, search, *limit)
+	if err != nil {
+		return err
+	}
+
+	return out.users(users)
+}
+
+func show(ctx context.Context, service *service.Service, out printer, args []string) error {
+	idOrEmail, err := oneArg(args)
+	if err != nil {
+		return err
+	}
+
+	user, err := service.UserRecord(ctx, idOrEmail)
+	if err != nil {
+		return err
+	}
+
+	return out.user(user)
+}
+
+func verify(ctx context.Context, service *service.Service, out printer, args []string) error {
+	return update(ctx, service, out, args, service.MarkEmailVerified)
+}
+
+func suspend(ctx context.Context, service *service.Service, out printer, args []string) error {
+	return update(ctx, service, out, args, service.SuspendUser)
+}
+
+func unsuspend(ctx context.Context, service *service.Service, out printer, args []string) error {
+	return update(ctx, service, out, args, service.UnsuspendUser)
+}
+
+func recache(ctx context.Context, service *service.Service, out printer, args []string) error {
+	return update(ctx, service, out, args, service.RecacheUser)
+}
+
+func deleteUser(ctx context.Context, service *service.Service, out printer, args []string) error {
+	return update(ctx, service, out, args, func(ctx context.Context, id string) (models.User, error) {
+		return models.User{}, service.DeleteUser(ctx, id)
+	})
+}
+
+// update applies fn to the user given by id and prints the user afterwards.
+func update(
+	ctx context.Context,
+	service *service.Service,
+	out printer,
+	args []string,
+	fn func(ctx context.Context, id string) (models.User, error),
+) error {
+	id, err := oneArg(args)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fn(ctx, id); err != nil {
+		return err
+	}
+
+	user, err := service.UserRecord(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return out.user(user)
+}
+
+func orphans(ctx context.Context, service *service.Service, out printer, args []string) error {
+	fs := flag.NewFlagSet("orphans", flag.ContinueOnError)
+	remove := fs.Bool("delete", false, "delete the orphaned avatars")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *remove {
+		deleted, err := service.DeleteOrphanedAvatars(ctx)
+		if err != nil {
+			return err
+		}
+
+		return out.count("deleted", deleted)
+	}
+
+	ids, err := service.OrphanedAvatars(ctx)
+	if err != nil {
+		return err
+	}
+
+	return out.ids(ids)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/AlexMickh/proj-user/internal/app"
+	"github.com/AlexMickh/proj-user/internal/config"
+	"github.com/AlexMickh/proj-user/internal/service"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+)
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, service *service.Service, out printer, args []string) error
+}
+
+var commands = map[string]command{
+	"find":      {"find [-limit n] <id|email|name>", find},
+	"show":      {"show <id|email>", show},
+	"verify":    {"verify <id>", verify},
+	"suspend":   {"suspend <id>", suspend},
+	"unsuspend": {"unsuspend <id>", unsuspend},
+	"delete":    {"delete <id>", deleteUser},
+	"recache":   {"recache <id>", recache},
+	"orphans":   {"orphans [-delete]", orphans},
+}
+
+func main() {
+	format := flag.String("o", "table", "output format, table or json")
+	flag.Usage = usage
+
+	cfg := config.MustLoad()
+
+	if *format != "table" && *format != "json" {
+		fail(fmt.Errorf("unknown output format %q", *format))
+	}
+
+	args := flag.Args()
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer cancel()
+
+	// logs go to stderr, so the output can be piped
+	ctx = logger.New(ctx, []string{"stderr"}, cfg.Env)
+
+	deps := app.NewDeps(ctx, cfg)
+
+	err := cmd.run(ctx, deps.Service, printer{w: os.Stdout, json: *format == "json"}, args[1:])
+	deps.Close()
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, "usage: userctl "+cmd.usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "usage: userctl [-config path] [-o table|json] <command> [args]")
+	fmt.Fprintln(out, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintln(out, "  "+commands[name].usage)
+	}
+
+	fmt.Fprintln(out, "\nflags:")
+	flag.PrintDefaults()
+}
+
+func fail(err error) {
+	if errors.Is(err, storage.ErrUserNotFound) {
+		err = storage.ErrUserNotFound
+	}
+	fmt.Fprintln(os.Stderr, "userctl: "+err.Error())
+	os.Exit(1)
+}
+
+// errUsage makes main print the usage of the command.
+var errUsage = errors.New("invalid arguments")
+
+// oneArg returns the only positional argument of a command.
+func oneArg(args []string) (string, error) {
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return "", errUsage
+	}
+
+	return strings.TrimSpace(args[0]), nil
+}
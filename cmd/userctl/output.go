@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+)
+
+// printer writes the command results as aligned tables or as json.
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+type userView struct {
+	ID              string             `json:"id"`
+	Email           string             `json:"email"`
+	Name            string             `json:"name"`
+	Handle          string             `json:"handle,omitempty"`
+	About           string             `json:"about"`
+	Skills          []models.UserSkill `json:"skills"`
+	Provider        string             `json:"provider"`
+	AvatarKey       string             `json:"avatar_key"`
+	HasPassword     bool               `json:"has_password"`
+	IsEmailVerified bool               `json:"is_email_verified"`
+	Status          string             `json:"status"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	SuspendedAt     *time.Time         `json:"suspended_at,omitempty"`
+	DeletedAt       *time.Time         `json:"deleted_at,omitempty"`
+}
+
+func toUserView(user models.UserRecord) userView {
+	status := "active"
+	switch {
+	case user.DeletedAt != nil:
+		status = "deleted"
+	case user.SuspendedAt != nil:
+		status = "suspended"
+	}
+
+	return userView{
+		ID:              user.ID,
+		Email:           user.Email,
+		Name:            user.Name,
+		Handle:          user.Handle,
+		About:           user.About,
+		Skills:          user.Skills,
+		Provider:        user.Provider,
+		AvatarKey:       user.AvatarKey,
+		HasPassword:     user.HasPassword,
+		IsEmailVerified: user.IsEmailVerified,
+		Status:          status,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		SuspendedAt:     user.SuspendedAt,
+		DeletedAt:       user.DeletedAt,
+	}
+}
+
+func (p printer) users(users []models.UserRecord) error {
+	views := make([]userView, 0, len(users))
+	for _, user := range users {
+		views = append(views, toUserView(user))
+	}
+
+	if p.json {
+		return p.encode(views)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tEMAIL\tNAME\tPROVIDER\tVERIFIED\tSTATUS\tCREATED")
+	for _, v := range views {
+		fmt.Fprintf(
+			tw,
+			"%s\t%s\t%s\t%s\t%t\t%s\t%s\n",
+			v.ID,
+			v.Email,
+			v.Name,
+			v.Provider,
+			v.IsEmailVerified,
+			v.Status,
+			formatTime(&v.CreatedAt),
+		)
+	}
+
+	return tw.Flush()
+}
+
+func (p printer) user(user models.UserRecord) error {
+	v := toUserView(user)
+
+	if p.json {
+		return p.encode(v)
+	}
+
+	skills := make([]string, 0, len(v.Skills))
+	for _, skill := range v.Skills {
+		skills = append(skills, fmt.Sprintf("%s:%d:%d", skill.Slug, skill.Level, skill.Years))
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	rows := [][2]string{
+		{"id", v.ID},
+		{"email", v.Email},
+		{"name", v.Name},
+		{"handle", v.Handle},
+		{"about", v.About},
+		{"skills", strings.Join(skills, ";")},
+		{"provider", v.Provider},
+		{"avatar key", v.AvatarKey},
+		{"has password", fmt.Sprint(v.HasPassword)},
+		{"email verified", fmt.Sprint(v.IsEmailVerified)},
+		{"status", v.Status},
+		{"created at", formatTime(&v.CreatedAt)},
+		{"updated at", formatTime(&v.UpdatedAt)},
+		{"suspended at", formatTime(v.SuspendedAt)},
+		{"deleted at", formatTime(v.DeletedAt)},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%s:\t%s\n", row[0], row[1])
+	}
+
+	return tw.Flush()
+}
+
+func (p printer) ids(ids []string) error {
+	if p.json {
+		if ids == nil {
+			ids = []string{}
+		}
+		return p.encode(ids)
+	}
+
+	for _, id := range ids {
+		if _, err := fmt.Fprintln(p.w, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p printer) count(name string, n int) error {
+	if p.json {
+		return p.encode(map[string]int{name: n})
+	}
+
+	_, err := fmt.Fprintf(p.w, "%s: %d\n", name, n)
+	return err
+}
+
+func (p printer) encode(v any) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.DateTime)
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/AlexMickh/proj-protos/pkg/api/user"
 	"github.com/AlexMickh/proj-user/internal/config"
 	"github.com/AlexMickh/proj-user/internal/grpc/server"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/providers"
 	"github.com/AlexMickh/proj-user/internal/service"
 	"github.com/AlexMickh/proj-user/internal/storage/minio"
 	"github.com/AlexMickh/proj-user/internal/storage/postgres"
 	"github.com/AlexMickh/proj-user/internal/storage/redis"
+	"github.com/AlexMickh/proj-user/migrations"
+	"github.com/AlexMickh/proj-user/pkg/avatar"
+	"github.com/AlexMickh/proj-user/pkg/hasher"
 	"github.com/AlexMickh/proj-user/pkg/logger"
 	"github.com/AlexMickh/proj-user/pkg/minio_client"
 	"github.com/AlexMickh/proj-user/pkg/postgres_client"
@@ -24,15 +30,43 @@ import (
 )
 
 type App struct {
-	cfg    *config.Config
-	db     *pgxpool.Pool
-	s3     *minio_lib.Client
-	cash   *redis_lib.Client
-	server *grpc.Server
+	cfg      *config.Config
+	deps     *Deps
+	server   *grpc.Server
+	stopJobs context.CancelFunc
+}
+
+// Deps are the storage clients and the service built on them,
+// shared by the server and the command line tools.
+type Deps struct {
+	DB      *pgxpool.Pool
+	S3      *minio_lib.Client
+	Cash    *redis_lib.Client
+	Storage *postgres.Storage
+	Service *service.Service
 }
 
 func Register(ctx context.Context, cfg *config.Config) *App {
-	const op = "app.Register"
+	deps := NewDeps(ctx, cfg)
+
+	srv := server.New(deps.Service)
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(logger.Interceptor(ctx)),
+		grpc.StreamInterceptor(logger.StreamInterceptor(ctx)),
+	)
+	user.RegisterUserServer(server, srv)
+
+	return &App{
+		cfg:    cfg,
+		deps:   deps,
+		server: server,
+	}
+}
+
+// NewDeps connects to the storages and builds the service,
+// any failure is fatal.
+func NewDeps(ctx context.Context, cfg *config.Config) *Deps {
+	const op = "app.NewDeps"
 
 	log := logger.FromCtx(ctx).With(zap.String("op", op))
 
@@ -46,7 +80,8 @@ func Register(ctx context.Context, cfg *config.Config) *App {
 		cfg.DB.Name,
 		cfg.DB.MinPools,
 		cfg.DB.MaxPools,
-		cfg.DB.MigrationsPath,
+		migrations.Source(cfg.DB.MigrationsPath),
+		cfg.DB.AutoMigrate,
 	)
 	if err != nil {
 		log.Fatal("failed to init postgres", zap.Error(err))
@@ -67,7 +102,7 @@ func Register(ctx context.Context, cfg *config.Config) *App {
 		log.Fatal("failed to init minio", zap.Error(err))
 	}
 
-	minio := minio.New(s3, cfg.Minio.BucketName)
+	minio := minio.New(s3, cfg.Minio.BucketName, cfg.Minio.UrlExpiration)
 
 	log.Info("initing redis")
 	cash, err := redis_client.New(
@@ -81,24 +116,72 @@ func Register(ctx context.Context, cfg *config.Config) *App {
 		log.Fatal("failed to init redis", zap.Error(err))
 	}
 
-	redis := redis.New(cash, cfg.Redis.Expiration)
+	redis := redis.New(cash, cfg.Redis.Expiration, cfg.Redis.UrlExpiration)
+
+	log.Info("initing hasher")
+	hasher, err := hasher.New(
+		cfg.Hasher.Algorithm,
+		hasher.Argon2Params{
+			Time:    cfg.Hasher.Argon2Time,
+			Memory:  cfg.Hasher.Argon2Memory,
+			Threads: cfg.Hasher.Argon2Threads,
+			KeyLen:  cfg.Hasher.Argon2KeyLen,
+			SaltLen: cfg.Hasher.SaltLen,
+		},
+		cfg.Hasher.BcryptCost,
+	)
+	if err != nil {
+		log.Fatal("failed to init hasher", zap.Error(err))
+	}
 
-	log.Info("initing service")
-	service := service.New(postgres, minio, redis)
+	images := avatar.New(cfg.Avatar.MaxWidth, cfg.Avatar.MaxHeight, cfg.Avatar.ThumbnailSizes)
 
-	srv := server.New(service)
-	server := grpc.NewServer(grpc.UnaryInterceptor(logger.Interceptor(ctx)))
-	user.RegisterUserServer(server, srv)
+	configured := make([]models.Provider, 0, len(cfg.Providers))
+	for _, provider := range cfg.Providers {
+		configured = append(configured, models.Provider{
+			Name:       provider.Name,
+			TrustEmail: provider.TrustEmail,
+		})
+	}
+	providers := providers.New(configured)
 
-	return &App{
-		cfg:    cfg,
-		db:     db,
-		s3:     s3,
-		cash:   cash,
-		server: server,
+	log.Info("syncing providers")
+	err = postgres.SyncProviders(ctx, providers.Providers())
+	if err != nil {
+		log.Fatal("failed to sync providers", zap.Error(err))
+	}
+
+	log.Info("initing service")
+	service := service.New(
+		postgres,
+		minio,
+		redis,
+		hasher,
+		images,
+		providers,
+		cfg.Deletion.GracePeriod,
+		cfg.Tokens.VerificationTTL,
+		cfg.Tokens.PasswordResetTTL,
+		cfg.Tokens.ResendInterval,
+		cfg.Avatar.MaxSize,
+		cfg.Avatar.OrphanMinAge,
+	)
+
+	return &Deps{
+		DB:      db,
+		S3:      s3,
+		Cash:    cash,
+		Storage: postgres,
+		Service: service,
 	}
 }
 
+func (d *Deps) Close() {
+	d.DB.Close()
+	d.S3.CredContext().Client.CloseIdleConnections()
+	d.Cash.Close()
+}
+
 func (a *App) Run(ctx context.Context) {
 	const op = "app.Run"
 
@@ -118,11 +201,51 @@ func (a *App) Run(ctx context.Context) {
 	}()
 
 	log.Info("server started", zap.String("addr", a.cfg.Server.Addr))
+
+	// the jobs outlive ctx and are stopped by GracefulStop only
+	jobsCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	a.stopJobs = cancel
+
+	go runPeriodic(jobsCtx, "purge deleted users", a.cfg.Deletion.PurgeInterval, func(ctx context.Context) error {
+		purged, err := a.deps.Service.PurgeDeletedUsers(ctx)
+		if purged > 0 {
+			logger.FromCtx(ctx).Info("deleted users purged", zap.Int("count", purged))
+		}
+		return err
+	})
+
+	go runPeriodic(jobsCtx, "delete orphaned avatars", a.cfg.Avatar.ReconcileInterval, func(ctx context.Context) error {
+		deleted, err := a.deps.Service.DeleteOrphanedAvatars(ctx)
+		if deleted > 0 {
+			logger.FromCtx(ctx).Info("orphaned avatars deleted", zap.Int("count", deleted))
+		}
+		return err
+	})
 }
 
 func (a *App) GracefulStop() {
-	a.db.Close()
-	a.s3.CredContext().Client.CloseIdleConnections()
-	a.cash.Close()
+	if a.stopJobs != nil {
+		a.stopJobs()
+	}
 	a.server.GracefulStop()
+	a.deps.Close()
+}
+
+// runPeriodic calls job every interval until ctx is done.
+func runPeriodic(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	log := logger.FromCtx(ctx).With(zap.String("job", name))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.Error("job failed", zap.Error(err))
+			}
+		}
+	}
 }
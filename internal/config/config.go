@@ -10,11 +10,16 @@ import (
 )
 
 type Config struct {
-	Env    string       `yaml:"env" env-default:"prod"`
-	Server ServerConfig `yaml:"server"`
-	DB     DBConfig     `yaml:"db"`
-	Redis  RedisConfig  `yaml:"redis"`
-	Minio  MinioConfig  `yaml:"minio"`
+	Env       string           `yaml:"env" env-default:"prod"`
+	Server    ServerConfig     `yaml:"server"`
+	DB        DBConfig         `yaml:"db"`
+	Redis     RedisConfig      `yaml:"redis"`
+	Minio     MinioConfig      `yaml:"minio"`
+	Hasher    HasherConfig     `yaml:"hasher"`
+	Deletion  DeletionConfig   `yaml:"deletion"`
+	Tokens    TokensConfig     `yaml:"tokens"`
+	Avatar    AvatarConfig     `yaml:"avatar"`
+	Providers []ProviderConfig `yaml:"providers"`
 }
 
 type ServerConfig struct {
@@ -31,25 +36,63 @@ type DBConfig struct {
 	Name           string `env:"DB_NAME" yaml:"name" env-default:"chat"`
 	MinPools       int    `env:"DB_MIN_POOLS" yaml:"min_pools" env-default:"3"`
 	MaxPools       int    `env:"DB_MAX_POOLS" yaml:"max_pools" env-default:"5"`
-	MigrationsPath string `env:"MIGRATIONS_PATH" yaml:"migrations_path" env-default:"./migrations"`
+	MigrationsPath string `env:"MIGRATIONS_PATH" yaml:"migrations_path"`
+	AutoMigrate    bool   `env:"DB_AUTO_MIGRATE" yaml:"auto_migrate" env-default:"true"`
 }
 
 type RedisConfig struct {
-	Host       string        `env:"REDIS_HOST" yaml:"host" env-default:"localhost"`
-	Port       int           `env:"REDIS_PORT" yaml:"port" env-default:"6379"`
-	User       string        `env:"REDIS_USER" yaml:"user" env-default:"root"`
-	Password   string        `env:"REDIS_PASSWORD" yaml:"password" env-default:"root"`
-	DB         int           `env:"REDIS_DB" yaml:"db" env-default:"0"`
-	Expiration time.Duration `env:"REDIS_EXPIRATION" yaml:"expire_time" env-default:"24h"`
+	Host          string        `env:"REDIS_HOST" yaml:"host" env-default:"localhost"`
+	Port          int           `env:"REDIS_PORT" yaml:"port" env-default:"6379"`
+	User          string        `env:"REDIS_USER" yaml:"user" env-default:"root"`
+	Password      string        `env:"REDIS_PASSWORD" yaml:"password" env-default:"root"`
+	DB            int           `env:"REDIS_DB" yaml:"db" env-default:"0"`
+	Expiration    time.Duration `env:"REDIS_EXPIRATION" yaml:"expire_time" env-default:"24h"`
+	UrlExpiration time.Duration `env:"REDIS_URL_EXPIRATION" yaml:"url_expire_time" env-default:"30m"`
 }
 
 type MinioConfig struct {
-	Endpoint   string `env:"MINIO_ENDPOINT" yaml:"endpoint" env-default:"localhost:9000"`
-	Port       int    `env:"MINIO_PORT" yaml:"port" env-default:"9000"`
-	User       string `env:"MINIO_ROOT_USER" yaml:"user" env-default:"minio"`
-	Password   string `env:"MINIO_ROOT_PASSWORD" yaml:"password" env-required:"true"`
-	BucketName string `env:"MINIO_BUCKET_NAME" yaml:"bucket_name" env-default:"users"`
-	IsUseSsl   bool   `env:"MINIO_USE_SSL" yaml:"is_use_ssl" env-default:"false"`
+	Endpoint      string        `env:"MINIO_ENDPOINT" yaml:"endpoint" env-default:"localhost:9000"`
+	Port          int           `env:"MINIO_PORT" yaml:"port" env-default:"9000"`
+	User          string        `env:"MINIO_ROOT_USER" yaml:"user" env-default:"minio"`
+	Password      string        `env:"MINIO_ROOT_PASSWORD" yaml:"password" env-required:"true"`
+	BucketName    string        `env:"MINIO_BUCKET_NAME" yaml:"bucket_name" env-default:"users"`
+	IsUseSsl      bool          `env:"MINIO_USE_SSL" yaml:"is_use_ssl" env-default:"false"`
+	UrlExpiration time.Duration `env:"MINIO_URL_EXPIRATION" yaml:"url_expiration" env-default:"1h"`
+}
+
+type HasherConfig struct {
+	Algorithm     string `env:"HASHER_ALGORITHM" yaml:"algorithm" env-default:"argon2id"`
+	Argon2Time    uint32 `env:"HASHER_ARGON2_TIME" yaml:"argon2_time" env-default:"3"`
+	Argon2Memory  uint32 `env:"HASHER_ARGON2_MEMORY" yaml:"argon2_memory" env-default:"65536"`
+	Argon2Threads uint8  `env:"HASHER_ARGON2_THREADS" yaml:"argon2_threads" env-default:"2"`
+	Argon2KeyLen  uint32 `env:"HASHER_ARGON2_KEY_LEN" yaml:"argon2_key_len" env-default:"32"`
+	SaltLen       uint32 `env:"HASHER_SALT_LEN" yaml:"salt_len" env-default:"16"`
+	BcryptCost    int    `env:"HASHER_BCRYPT_COST" yaml:"bcrypt_cost" env-default:"12"`
+}
+
+type DeletionConfig struct {
+	GracePeriod   time.Duration `env:"DELETION_GRACE_PERIOD" yaml:"grace_period" env-default:"720h"`
+	PurgeInterval time.Duration `env:"DELETION_PURGE_INTERVAL" yaml:"purge_interval" env-default:"1h"`
+}
+
+type TokensConfig struct {
+	VerificationTTL  time.Duration `env:"TOKENS_VERIFICATION_TTL" yaml:"verification_ttl" env-default:"24h"`
+	PasswordResetTTL time.Duration `env:"TOKENS_PASSWORD_RESET_TTL" yaml:"password_reset_ttl" env-default:"1h"`
+	ResendInterval   time.Duration `env:"TOKENS_RESEND_INTERVAL" yaml:"resend_interval" env-default:"1m"`
+}
+
+type AvatarConfig struct {
+	MaxSize           int64         `env:"AVATAR_MAX_SIZE" yaml:"max_size" env-default:"5242880"`
+	MaxWidth          int           `env:"AVATAR_MAX_WIDTH" yaml:"max_width" env-default:"4096"`
+	MaxHeight         int           `env:"AVATAR_MAX_HEIGHT" yaml:"max_height" env-default:"4096"`
+	ThumbnailSizes    []int         `env:"AVATAR_THUMBNAIL_SIZES" yaml:"thumbnail_sizes" env-default:"64,256,512"`
+	OrphanMinAge      time.Duration `env:"AVATAR_ORPHAN_MIN_AGE" yaml:"orphan_min_age" env-default:"1h"`
+	ReconcileInterval time.Duration `env:"AVATAR_RECONCILE_INTERVAL" yaml:"reconcile_interval" env-default:"24h"`
+}
+
+type ProviderConfig struct {
+	Name       string `yaml:"name"`
+	TrustEmail bool   `yaml:"trust_email"`
 }
 
 func MustLoad() *Config {
@@ -72,6 +115,22 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
 
+	if cfg.Deletion.PurgeInterval <= 0 {
+		return nil, fmt.Errorf("deletion purge interval must be positive, got %s", cfg.Deletion.PurgeInterval)
+	}
+
+	if cfg.Avatar.ReconcileInterval <= 0 {
+		return nil, fmt.Errorf("avatar reconcile interval must be positive, got %s", cfg.Avatar.ReconcileInterval)
+	}
+
+	if cfg.Redis.UrlExpiration >= cfg.Minio.UrlExpiration {
+		return nil, fmt.Errorf(
+			"redis url expiration %s must be shorter than minio url expiration %s",
+			cfg.Redis.UrlExpiration,
+			cfg.Minio.UrlExpiration,
+		)
+	}
+
 	return cfg, nil
 }
 
@@ -3,4 +3,29 @@ package consts
 const (
 	FieldProvider  = "fields"
 	YandexProvider = "yandex"
+	GitHubProvider = "github"
+	GoogleProvider = "google"
+	GitLabProvider = "gitlab"
+	VKProvider     = "vk"
 )
+
+const (
+	EmailVerificationToken = "email_verification"
+	PasswordResetToken     = "password_reset"
+	EmailChangeToken       = "email_change"
+)
+
+const (
+	SkillLevelUnspecified = iota
+	SkillLevelJunior
+	SkillLevelMiddle
+	SkillLevelSenior
+	SkillLevelLead
+)
+
+const MaxSkillYears = 70
+
+const MaxNameLength = 50
+
+// MaxPasswordLength is the most bytes bcrypt can hash.
+const MaxPasswordLength = 72
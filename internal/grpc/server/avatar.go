@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"io"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/service"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) UploadAvatar(stream user.User_UploadAvatarServer) error {
+	const op = "grpc.server.UploadAvatar"
+
+	ctx := stream.Context()
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return err
+	}
+
+	req, err := stream.Recv()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			log.Error("avatar is empty")
+			return status.Error(codes.InvalidArgument, "avatar is required")
+		}
+		log.Error("failed to receive chunk", zap.Error(err))
+		return err
+	}
+
+	reader := &chunkReader{stream: stream, chunk: req.GetChunk()}
+
+	userInfo, err := s.service.UploadAvatar(ctx, id, reader)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, service.ErrAvatarTooLarge) {
+			log.Error("avatar is too large")
+			return status.Error(codes.InvalidArgument, service.ErrAvatarTooLarge.Error())
+		}
+		if errors.Is(err, service.ErrInvalidAvatar) {
+			log.Error("invalid avatar", zap.Error(err))
+			return status.Error(codes.InvalidArgument, service.ErrInvalidAvatar.Error())
+		}
+		if reader.err != nil {
+			log.Error("failed to receive chunk", zap.Error(reader.err))
+			return reader.err
+		}
+		log.Error("failed to upload avatar", zap.Error(err))
+		return status.Error(codes.Internal, "failed to upload avatar")
+	}
+
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
+	return stream.SendAndClose(&user.UploadAvatarResponse{
+		User: userType,
+	})
+}
+
+// chunkReader exposes the chunks of an avatar upload stream as an io.Reader.
+type chunkReader struct {
+	stream user.User_UploadAvatarServer
+	chunk  []byte
+	// err keeps a transport error, so it isn't reported as a storage failure
+	err error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				r.err = err
+			}
+			return 0, err
+		}
+		r.chunk = req.GetChunk()
+	}
+
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+
+	return n, nil
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/service"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func (s *Server) RequestEmailChange(
+	ctx context.Context,
+	req *user.RequestEmailChangeRequest,
+) (*user.RequestEmailChangeResponse, error) {
+	const op = "grpc.server.RequestEmailChange"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	if req.GetEmail() == "" {
+		log.Error("email is empty")
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	email, err := normalizeEmail(req.GetEmail())
+	if err != nil {
+		log.Error("invalid email", zap.Error(err))
+		return nil, err
+	}
+
+	changeToken, err := s.service.RequestEmailChange(ctx, id, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, service.ErrSameEmail) {
+			log.Error("email is not changed")
+			return nil, status.Error(codes.InvalidArgument, service.ErrSameEmail.Error())
+		}
+		if errors.Is(err, storage.ErrUserAlreadyExists) {
+			log.Error("email is taken")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrUserAlreadyExists.Error())
+		}
+		if errors.Is(err, service.ErrTooManyRequests) {
+			log.Error("email change throttled")
+			return nil, status.Error(codes.ResourceExhausted, service.ErrTooManyRequests.Error())
+		}
+		log.Error("failed to request email change", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to request email change")
+	}
+
+	return &user.RequestEmailChangeResponse{
+		ChangeToken: changeToken,
+	}, nil
+}
+
+func (s *Server) ConfirmEmailChange(ctx context.Context, req *user.ConfirmEmailChangeRequest) (*emptypb.Empty, error) {
+	const op = "grpc.server.ConfirmEmailChange"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetToken() == "" {
+		log.Error("token is empty")
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	err := s.service.ConfirmEmailChange(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("invalid change token", zap.Error(err))
+			return nil, status.Error(codes.InvalidArgument, storage.ErrTokenNotFound.Error())
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, storage.ErrUserAlreadyExists) {
+			log.Error("email is taken")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrUserAlreadyExists.Error())
+		}
+		log.Error("failed to confirm email change", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to confirm email change")
+	}
+
+	return &emptypb.Empty{}, nil
+}
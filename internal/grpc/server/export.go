@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) ExportUsers(req *user.ExportUsersRequest, stream user.User_ExportUsersServer) error {
+	const op = "grpc.server.ExportUsers"
+
+	ctx := stream.Context()
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if err := requireAdmin(ctx); err != nil {
+		log.Error("caller is not an admin")
+		return err
+	}
+
+	filter := models.UsersExportFilter{
+		Provider:        strings.ToLower(req.GetProvider()),
+		IsEmailVerified: req.IsEmailVerified,
+	}
+	if req.GetCreatedFrom() != nil {
+		filter.CreatedFrom = req.GetCreatedFrom().AsTime()
+	}
+	if req.GetCreatedTo() != nil {
+		filter.CreatedTo = req.GetCreatedTo().AsTime()
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && !filter.CreatedFrom.Before(filter.CreatedTo) {
+		log.Error("invalid created at range")
+		return status.Error(codes.InvalidArgument, "created_from must be before created_to")
+	}
+
+	var sent int
+	err := s.service.ExportUsers(ctx, filter, func(exported models.ExportedUser) error {
+		err := stream.Send(&user.ExportUsersResponse{
+			User: toExportedUserType(exported),
+		})
+		if err != nil {
+			return sendError{err}
+		}
+		sent++
+
+		return nil
+	})
+	if err != nil {
+		var sendErr sendError
+		if errors.As(err, &sendErr) {
+			log.Error("failed to send user", zap.Int("sent", sent), zap.Error(err))
+			return sendErr.err
+		}
+		log.Error("failed to export users", zap.Int("sent", sent), zap.Error(err))
+		return status.Error(codes.Internal, "failed to export users")
+	}
+
+	log.Info("users exported", zap.Int("count", sent))
+
+	return nil
+}
+
+// sendError marks a failed stream send, so its status reaches the client as is.
+type sendError struct {
+	err error
+}
+
+func (e sendError) Error() string {
+	return e.err.Error()
+}
+
+func (e sendError) Unwrap() error {
+	return e.err
+}
+
+func toExportedUserType(exported models.ExportedUser) *user.ExportedUserType {
+	skills := make([]*user.UserSkill, 0, len(exported.Skills))
+	for _, skill := range exported.Skills {
+		skills = append(skills, &user.UserSkill{
+			Slug:  skill.Slug,
+			Level: user.SkillLevel(skill.Level),
+			Years: int32(skill.Years),
+		})
+	}
+
+	return &user.ExportedUserType{
+		Id:              exported.ID,
+		Email:           exported.Email,
+		Name:            exported.Name,
+		Handle:          exported.Handle,
+		About:           exported.About,
+		SkillDetails:    skills,
+		Provider:        exported.Provider,
+		IsEmailVerified: exported.IsEmailVerified,
+		CreatedAt:       timestamppb.New(exported.CreatedAt),
+		UpdatedAt:       timestamppb.New(exported.UpdatedAt),
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/service"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *Server) CheckHandleAvailable(
+	ctx context.Context,
+	req *user.CheckHandleAvailableRequest,
+) (*user.CheckHandleAvailableResponse, error) {
+	const op = "grpc.server.CheckHandleAvailable"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetHandle() == "" {
+		log.Error("handle is empty")
+		return nil, status.Error(codes.InvalidArgument, "handle is required")
+	}
+
+	userHandle, err := normalizeHandle(req.GetHandle())
+	if err != nil {
+		log.Error("invalid handle", zap.Error(err))
+		return nil, err
+	}
+
+	available, suggestions, err := s.service.CheckHandleAvailable(ctx, userHandle)
+	if err != nil {
+		log.Error("failed to check handle", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to check handle")
+	}
+
+	return &user.CheckHandleAvailableResponse{
+		Available:   available,
+		Suggestions: suggestions,
+	}, nil
+}
+
+func (s *Server) GetUserByHandle(ctx context.Context, req *user.GetUserByHandleRequest) (*user.GetUserByHandleResponse, error) {
+	const op = "grpc.server.GetUserByHandle"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetHandle() == "" {
+		log.Error("handle is empty")
+		return nil, status.Error(codes.InvalidArgument, "handle is required")
+	}
+
+	userHandle, err := normalizeHandle(req.GetHandle())
+	if err != nil {
+		log.Error("invalid handle", zap.Error(err))
+		return nil, err
+	}
+
+	userInfo, err := s.service.UserByHandle(ctx, userHandle)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, service.ErrEmailNotVerify) {
+			log.Error("email not verify", zap.Error(err))
+			return nil, status.Error(codes.Unauthenticated, service.ErrEmailNotVerify.Error())
+		}
+		log.Error("failed to get user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get user")
+	}
+
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
+	return &user.GetUserByHandleResponse{
+		User: userType,
+	}, nil
+}
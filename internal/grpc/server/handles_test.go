@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"github.com/AlexMickh/proj-user/pkg/utils/handle"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+type updateService struct {
+	Service
+	err error
+}
+
+func (s updateService) UpdateUser(ctx context.Context, id string, update models.UserUpdate) (models.User, error) {
+	return models.User{}, fmt.Errorf("service.UpdateUser: %w", s.err)
+}
+
+func TestUpdateUserHandleErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "taken", err: storage.ErrHandleTaken, wantCode: codes.AlreadyExists},
+		{name: "reserved", err: handle.ErrReserved, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(updateService{err: tt.err})
+			ctx := context.WithValue(context.Background(), logger.Key, zap.NewNop())
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("user_id", "id"))
+
+			_, err := s.UpdateUser(ctx, &user.UpdateUserRequest{
+				Handle:     "alex_m",
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"handle"}},
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("UpdateUser() code = %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
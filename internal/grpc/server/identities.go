@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/service"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *Server) LinkIdentity(ctx context.Context, req *user.LinkIdentityRequest) (*user.LinkIdentityResponse, error) {
+	const op = "grpc.server.LinkIdentity"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	if req.GetProvider() == "" {
+		log.Error("provider is empty")
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	if req.GetProviderSubject() == "" {
+		log.Error("provider subject is empty")
+		return nil, status.Error(codes.InvalidArgument, "provider subject is required")
+	}
+
+	identity, err := s.service.LinkIdentity(ctx, id, req.GetProvider(), req.GetProviderSubject())
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownProvider) {
+			log.Error("provider is not supported", zap.String("provider", req.GetProvider()))
+			return nil, status.Error(codes.InvalidArgument, service.ErrUnknownProvider.Error())
+		}
+		if errors.Is(err, storage.ErrIdentityLinked) {
+			log.Error("identity is already linked")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrIdentityLinked.Error())
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		log.Error("failed to link identity", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to link identity")
+	}
+
+	return &user.LinkIdentityResponse{
+		Identity: toIdentityType(identity),
+	}, nil
+}
+
+func (s *Server) UnlinkIdentity(ctx context.Context, req *user.UnlinkIdentityRequest) (*emptypb.Empty, error) {
+	const op = "grpc.server.UnlinkIdentity"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	if req.GetProvider() == "" {
+		log.Error("provider is empty")
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+	if req.GetProviderSubject() == "" {
+		log.Error("provider subject is empty")
+		return nil, status.Error(codes.InvalidArgument, "provider subject is required")
+	}
+
+	err = s.service.UnlinkIdentity(ctx, id, req.GetProvider(), req.GetProviderSubject())
+	if err != nil {
+		if errors.Is(err, storage.ErrIdentityNotFound) {
+			log.Error("identity not found")
+			return nil, status.Error(codes.NotFound, storage.ErrIdentityNotFound.Error())
+		}
+		if errors.Is(err, storage.ErrLastLoginMethod) {
+			log.Error("last login method")
+			return nil, status.Error(codes.FailedPrecondition, storage.ErrLastLoginMethod.Error())
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		log.Error("failed to unlink identity", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to unlink identity")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ListIdentities(ctx context.Context, req *user.ListIdentitiesRequest) (*user.ListIdentitiesResponse, error) {
+	const op = "grpc.server.ListIdentities"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	identities, err := s.service.Identities(ctx, id)
+	if err != nil {
+		log.Error("failed to get identities", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get identities")
+	}
+
+	resp := make([]*user.IdentityType, 0, len(identities))
+	for _, identity := range identities {
+		resp = append(resp, toIdentityType(identity))
+	}
+
+	return &user.ListIdentitiesResponse{
+		Identities: resp,
+	}, nil
+}
+
+func toIdentityType(identity models.Identity) *user.IdentityType {
+	return &user.IdentityType{
+		Provider:        identity.Provider,
+		ProviderSubject: identity.Subject,
+		CreatedAt:       timestamppb.New(identity.CreatedAt),
+	}
+}
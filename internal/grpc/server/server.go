@@ -3,13 +3,19 @@ package server
 import (
 	"context"
 	"errors"
+	"io"
+	"strings"
 
 	"github.com/AlexMickh/proj-protos/pkg/api/user"
 	"github.com/AlexMickh/proj-user/internal/consts"
 	"github.com/AlexMickh/proj-user/internal/models"
 	"github.com/AlexMickh/proj-user/internal/service"
 	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/internal/validation"
 	"github.com/AlexMickh/proj-user/pkg/logger"
+	"github.com/AlexMickh/proj-user/pkg/utils/handle"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -25,15 +31,45 @@ type Service interface {
 		name string,
 		password string,
 		about string,
-		skills []string,
+		skills []models.UserSkill,
 		avatar []byte,
-	) (string, error)
+	) (string, string, error)
+	CreateUserWithProvider(
+		ctx context.Context,
+		provider string,
+		subject string,
+		email string,
+		login string,
+	) (string, string, error)
 	UserByEmail(ctx context.Context, email string) (models.User, error)
-	VerifyEmail(ctx context.Context, id string) error
+	VerifyCredentials(ctx context.Context, email string, password string) (models.User, error)
+	ResendVerification(ctx context.Context, email string) (string, error)
+	VerifyEmail(ctx context.Context, verificationToken string) error
+	RequestPasswordReset(ctx context.Context, email string) (string, error)
+	ResetPassword(ctx context.Context, resetToken string, newPassword string) error
+	RequestEmailChange(ctx context.Context, id string, email string) (string, error)
+	ConfirmEmailChange(ctx context.Context, changeToken string) error
 	UserById(ctx context.Context, id string) (models.User, error)
-	UsersBySkills(ctx context.Context, userId string, skills []string) ([]models.User, error)
+	UsersByIds(ctx context.Context, ids []string) ([]models.User, []string, error)
+	UserByHandle(ctx context.Context, handle string) (models.User, error)
+	CheckHandleAvailable(ctx context.Context, handle string) (bool, []string, error)
+	UpdateUser(ctx context.Context, id string, update models.UserUpdate) (models.User, error)
+	UploadAvatar(ctx context.Context, id string, avatar io.Reader) (models.User, error)
+	AvatarUrls(ctx context.Context, user models.User) (models.AvatarUrls, error)
+	DeleteUser(ctx context.Context, id string) error
+	RestoreUser(ctx context.Context, id string) (models.User, error)
+	UsersBySkills(ctx context.Context, userId string, query models.UsersQuery) (models.UsersPage, error)
+	ExportUsers(ctx context.Context, filter models.UsersExportFilter, fn func(models.ExportedUser) error) error
+	AddSkill(ctx context.Context, slug string, displayName string, category string) (models.Skill, error)
+	UpdateSkill(ctx context.Context, slug string, update models.SkillUpdate) (models.Skill, error)
+	Skills(ctx context.Context, category string, includeDeprecated bool) ([]models.Skill, error)
+	LinkIdentity(ctx context.Context, userId string, provider string, subject string) (models.Identity, error)
+	UnlinkIdentity(ctx context.Context, userId string, provider string, subject string) error
+	Identities(ctx context.Context, userId string) ([]models.Identity, error)
 }
 
+const maxUsersByIds = 100
+
 type Server struct {
 	user.UnimplementedUserServer
 	service Service
@@ -50,31 +86,26 @@ func (s *Server) CreateUser(ctx context.Context, req *user.CreateUserRequest) (*
 
 	log := logger.FromCtx(ctx).With(zap.String("op", op))
 
-	if req.GetEmail() == "" {
-		log.Error("email is empty")
-		return nil, status.Error(codes.InvalidArgument, "email is required")
-	}
-	if req.GetName() == "" {
-		log.Error("name is empty")
-		return nil, status.Error(codes.InvalidArgument, "name is required")
-	}
-	if req.GetPassword() == "" {
-		log.Error("password is empty")
-		return nil, status.Error(codes.InvalidArgument, "password is required")
+	skills, err := userSkills(req.GetSkills(), req.GetSkillDetails())
+	if err != nil {
+		log.Error("invalid skills", zap.Error(err))
+		return nil, err
 	}
-	if req.GetSkills() == nil {
-		log.Error("skills is empty")
-		return nil, status.Error(codes.InvalidArgument, "skills is required")
+
+	err = validation.NewUser(req.GetEmail(), req.GetName(), req.GetPassword(), skills)
+	if err != nil {
+		log.Error("invalid user", zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	id, err := s.service.CreateUser(
+	id, verificationToken, err := s.service.CreateUser(
 		ctx,
 		consts.FieldProvider,
 		req.GetEmail(),
 		req.GetName(),
 		req.GetPassword(),
 		req.GetAbout(),
-		req.GetSkills(),
+		skills,
 		req.GetAvatar(),
 	)
 	if err != nil {
@@ -86,12 +117,21 @@ func (s *Server) CreateUser(ctx context.Context, req *user.CreateUserRequest) (*
 			log.Error("skill not in the skills list")
 			return nil, status.Error(codes.InvalidArgument, storage.ErrInvalidSkills.Error())
 		}
+		if errors.Is(err, service.ErrAvatarTooLarge) {
+			log.Error("avatar is too large")
+			return nil, status.Error(codes.InvalidArgument, service.ErrAvatarTooLarge.Error())
+		}
+		if errors.Is(err, service.ErrInvalidAvatar) {
+			log.Error("invalid avatar", zap.Error(err))
+			return nil, status.Error(codes.InvalidArgument, service.ErrInvalidAvatar.Error())
+		}
 		log.Error("failed to create user", zap.Error(err))
 		return nil, status.Error(codes.Internal, "failed to create user")
 	}
 
 	return &user.CreateUserResponse{
-		Id: id,
+		Id:                id,
+		VerificationToken: verificationToken,
 	}, nil
 }
 
@@ -107,6 +147,12 @@ func (s *Server) CreateUserWithProvider(
 		log.Error("email is empty")
 		return nil, status.Error(codes.InvalidArgument, "email is required")
 	}
+
+	email, err := normalizeEmail(req.GetEmail())
+	if err != nil {
+		log.Error("invalid email", zap.Error(err))
+		return nil, err
+	}
 	if req.GetLogin() == "" {
 		log.Error("login is empty")
 		return nil, status.Error(codes.InvalidArgument, "login is required")
@@ -115,41 +161,47 @@ func (s *Server) CreateUserWithProvider(
 		log.Error("provider is empty")
 		return nil, status.Error(codes.InvalidArgument, "provider is required")
 	}
+	if req.GetProviderSubject() == "" {
+		log.Error("provider subject is empty")
+		return nil, status.Error(codes.InvalidArgument, "provider subject is required")
+	}
 
-	var provider string
-	switch req.GetProvider() {
-	case "YANDEX":
-		provider = consts.YandexProvider
-	default:
-		log.Error("provider is not supported", zap.String("provider", provider))
-		return nil, status.Error(codes.InvalidArgument, "provider is not supported")
+	if strings.EqualFold(req.GetProvider(), consts.FieldProvider) {
+		log.Error("provider is not supported", zap.String("provider", req.GetProvider()))
+		return nil, status.Error(codes.InvalidArgument, service.ErrUnknownProvider.Error())
 	}
 
-	id, err := s.service.CreateUser(
+	id, verificationToken, err := s.service.CreateUserWithProvider(
 		ctx,
-		provider,
-		req.GetEmail(),
+		req.GetProvider(),
+		req.GetProviderSubject(),
+		email,
 		req.GetLogin(),
-		"",
-		"",
-		nil,
-		nil,
 	)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserAlreadyExists) {
 			log.Error("user already exists")
 			return nil, status.Error(codes.InvalidArgument, storage.ErrUserAlreadyExists.Error())
 		}
-		if errors.Is(err, storage.ErrInvalidSkills) {
-			log.Error("skill not in the skills list")
-			return nil, status.Error(codes.InvalidArgument, storage.ErrInvalidSkills.Error())
+		if errors.Is(err, storage.ErrIdentityLinked) {
+			log.Error("identity is already linked")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrIdentityLinked.Error())
+		}
+		if errors.Is(err, storage.ErrUserSuspended) {
+			log.Error("user is suspended")
+			return nil, status.Error(codes.PermissionDenied, storage.ErrUserSuspended.Error())
+		}
+		if errors.Is(err, service.ErrUnknownProvider) {
+			log.Error("provider is not supported", zap.String("provider", req.GetProvider()))
+			return nil, status.Error(codes.InvalidArgument, service.ErrUnknownProvider.Error())
 		}
 		log.Error("failed to create user", zap.Error(err))
 		return nil, status.Error(codes.Internal, "failed to create user")
 	}
 
 	return &user.CreateUserWithProviderResponse{
-		Id: id,
+		Id:                id,
+		VerificationToken: verificationToken,
 	}, nil
 }
 
@@ -163,7 +215,13 @@ func (s *Server) GetUserByEmail(ctx context.Context, req *user.GetUserByEmailReq
 		return nil, status.Error(codes.InvalidArgument, "email is required")
 	}
 
-	userInfo, err := s.service.UserByEmail(ctx, req.GetEmail())
+	email, err := normalizeEmail(req.GetEmail())
+	if err != nil {
+		log.Error("invalid email", zap.Error(err))
+		return nil, err
+	}
+
+	userInfo, err := s.service.UserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Error("user not found", zap.Error(err))
@@ -177,17 +235,108 @@ func (s *Server) GetUserByEmail(ctx context.Context, req *user.GetUserByEmailReq
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
 
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
 	return &user.GetUserByEmailResponse{
-		User: &user.UserType{
-			Id:              userInfo.ID,
-			Email:           userInfo.Email,
-			Name:            userInfo.Name,
-			Password:        userInfo.Password,
-			About:           &userInfo.About,
-			Skills:          userInfo.Skills,
-			AvatarUrl:       userInfo.AvatarUrl,
-			IsEmailVerified: userInfo.IsEmailVerified,
-		},
+		User: userType,
+	}, nil
+}
+
+func (s *Server) VerifyCredentials(
+	ctx context.Context,
+	req *user.VerifyCredentialsRequest,
+) (*user.VerifyCredentialsResponse, error) {
+	const op = "grpc.server.VerifyCredentials"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetEmail() == "" {
+		log.Error("email is empty")
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	email, err := normalizeEmail(req.GetEmail())
+	if err != nil {
+		log.Error("invalid email", zap.Error(err))
+		return nil, err
+	}
+	if req.GetPassword() == "" {
+		log.Error("password is empty")
+		return nil, status.Error(codes.InvalidArgument, "password is required")
+	}
+
+	userInfo, err := s.service.VerifyCredentials(ctx, email, req.GetPassword())
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			log.Error("invalid credentials")
+			return nil, status.Error(codes.Unauthenticated, service.ErrInvalidCredentials.Error())
+		}
+		if errors.Is(err, service.ErrEmailNotVerify) {
+			log.Error("email not verify", zap.Error(err))
+			return nil, status.Error(codes.PermissionDenied, service.ErrEmailNotVerify.Error())
+		}
+		if errors.Is(err, storage.ErrUserSuspended) {
+			log.Error("user is suspended")
+			return nil, status.Error(codes.PermissionDenied, storage.ErrUserSuspended.Error())
+		}
+		log.Error("failed to verify credentials", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to verify credentials")
+	}
+
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
+	return &user.VerifyCredentialsResponse{
+		User: userType,
+	}, nil
+}
+
+func (s *Server) ResendVerification(
+	ctx context.Context,
+	req *user.ResendVerificationRequest,
+) (*user.ResendVerificationResponse, error) {
+	const op = "grpc.server.ResendVerification"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetEmail() == "" {
+		log.Error("email is empty")
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	email, err := normalizeEmail(req.GetEmail())
+	if err != nil {
+		log.Error("invalid email", zap.Error(err))
+		return nil, err
+	}
+
+	verificationToken, err := s.service.ResendVerification(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, service.ErrEmailAlreadyVerified) {
+			log.Error("email already verified")
+			return nil, status.Error(codes.FailedPrecondition, service.ErrEmailAlreadyVerified.Error())
+		}
+		if errors.Is(err, service.ErrTooManyRequests) {
+			log.Error("verification resend throttled")
+			return nil, status.Error(codes.ResourceExhausted, service.ErrTooManyRequests.Error())
+		}
+		log.Error("failed to resend verification", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to resend verification")
+	}
+
+	return &user.ResendVerificationResponse{
+		VerificationToken: verificationToken,
 	}, nil
 }
 
@@ -196,13 +345,17 @@ func (s *Server) VerifyEmail(ctx context.Context, req *user.VerifyEmailRequest)
 
 	log := logger.FromCtx(ctx).With(zap.String("op", op))
 
-	if req.GetId() == "" {
-		log.Error("id is empty")
-		return nil, status.Error(codes.InvalidArgument, "id is required")
+	if req.GetToken() == "" {
+		log.Error("token is empty")
+		return nil, status.Error(codes.InvalidArgument, "token is required")
 	}
 
-	err := s.service.VerifyEmail(ctx, req.GetId())
+	err := s.service.VerifyEmail(ctx, req.GetToken())
 	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("invalid verification token", zap.Error(err))
+			return nil, status.Error(codes.InvalidArgument, storage.ErrTokenNotFound.Error())
+		}
 		if errors.Is(err, storage.ErrUserNotFound) {
 			log.Error("user not found", zap.Error(err))
 			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
@@ -214,6 +367,79 @@ func (s *Server) VerifyEmail(ctx context.Context, req *user.VerifyEmailRequest)
 	return &emptypb.Empty{}, nil
 }
 
+func (s *Server) RequestPasswordReset(
+	ctx context.Context,
+	req *user.RequestPasswordResetRequest,
+) (*user.RequestPasswordResetResponse, error) {
+	const op = "grpc.server.RequestPasswordReset"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetEmail() == "" {
+		log.Error("email is empty")
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	email, err := normalizeEmail(req.GetEmail())
+	if err != nil {
+		log.Error("invalid email", zap.Error(err))
+		return nil, err
+	}
+
+	resetToken, err := s.service.RequestPasswordReset(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, service.ErrPasswordNotSet) {
+			log.Error("user has no password")
+			return nil, status.Error(codes.FailedPrecondition, service.ErrPasswordNotSet.Error())
+		}
+		if errors.Is(err, service.ErrTooManyRequests) {
+			log.Error("password reset throttled")
+			return nil, status.Error(codes.ResourceExhausted, service.ErrTooManyRequests.Error())
+		}
+		log.Error("failed to request password reset", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to request password reset")
+	}
+
+	return &user.RequestPasswordResetResponse{
+		ResetToken: resetToken,
+	}, nil
+}
+
+func (s *Server) ResetPassword(ctx context.Context, req *user.ResetPasswordRequest) (*emptypb.Empty, error) {
+	const op = "grpc.server.ResetPassword"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetToken() == "" {
+		log.Error("token is empty")
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	if err := validation.Password(req.GetNewPassword()); err != nil {
+		log.Error("invalid new password", zap.Error(err))
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err := s.service.ResetPassword(ctx, req.GetToken(), req.GetNewPassword())
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			log.Error("invalid reset token", zap.Error(err))
+			return nil, status.Error(codes.InvalidArgument, storage.ErrTokenNotFound.Error())
+		}
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		log.Error("failed to reset password", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to reset password")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
 func (s *Server) GetUserById(ctx context.Context, req *user.GetUserByIdRequest) (*user.GetUserByIdResponse, error) {
 	const op = "grpc.server.GetUserById"
 
@@ -239,17 +465,223 @@ func (s *Server) GetUserById(ctx context.Context, req *user.GetUserByIdRequest)
 		return nil, status.Error(codes.Internal, "failed to get user")
 	}
 
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
 	return &user.GetUserByIdResponse{
-		User: &user.UserType{
-			Id:              userInfo.ID,
-			Email:           userInfo.Email,
-			Name:            userInfo.Name,
-			Password:        userInfo.Password,
-			About:           &userInfo.About,
-			Skills:          userInfo.Skills,
-			AvatarUrl:       userInfo.AvatarUrl,
-			IsEmailVerified: userInfo.IsEmailVerified,
-		},
+		User: userType,
+	}, nil
+}
+
+func (s *Server) GetUsersByIds(ctx context.Context, req *user.GetUsersByIdsRequest) (*user.GetUsersByIdsResponse, error) {
+	const op = "grpc.server.GetUsersByIds"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	ids := req.GetIds()
+	if len(ids) == 0 {
+		log.Error("ids are empty")
+		return nil, status.Error(codes.InvalidArgument, "ids are required")
+	}
+	if len(ids) > maxUsersByIds {
+		log.Error("too many ids", zap.Int("count", len(ids)))
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids are allowed", maxUsersByIds)
+	}
+	for _, id := range ids {
+		if err := uuid.Validate(id); err != nil {
+			log.Error("invalid id", zap.String("id", id), zap.Error(err))
+			return nil, status.Errorf(codes.InvalidArgument, "invalid id %q", id)
+		}
+	}
+
+	users, notFound, err := s.service.UsersByIds(ctx, ids)
+	if err != nil {
+		log.Error("failed to get users", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get users")
+	}
+
+	userTypes := make([]*user.UserType, 0, len(users))
+	for _, userInfo := range users {
+		userType, err := s.toUserType(ctx, userInfo)
+		if err != nil {
+			log.Error("failed to get avatar urls", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to get avatar urls")
+		}
+		userTypes = append(userTypes, userType)
+	}
+
+	return &user.GetUsersByIdsResponse{
+		Users:       userTypes,
+		NotFoundIds: notFound,
+	}, nil
+}
+
+func (s *Server) UpdateUser(ctx context.Context, req *user.UpdateUserRequest) (*user.UpdateUserResponse, error) {
+	const op = "grpc.server.UpdateUser"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	paths := req.GetUpdateMask().GetPaths()
+	if len(paths) == 0 {
+		log.Error("update mask is empty")
+		return nil, status.Error(codes.InvalidArgument, "update mask is required")
+	}
+
+	var update models.UserUpdate
+	for _, path := range paths {
+		switch path {
+		case "name":
+			if err := validation.Name(req.GetName()); err != nil {
+				log.Error("invalid name", zap.Error(err))
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			name := req.GetName()
+			update.Name = &name
+		case "handle":
+			// an empty handle removes it
+			userHandle := req.GetHandle()
+			if userHandle != "" {
+				userHandle, err = normalizeHandle(userHandle)
+				if err != nil {
+					log.Error("invalid handle", zap.Error(err))
+					return nil, err
+				}
+			}
+			update.Handle = &userHandle
+		case "about":
+			about := req.GetAbout()
+			update.About = &about
+		case "skills":
+			skills, err := userSkills(req.GetSkills(), req.GetSkillDetails())
+			if err != nil {
+				log.Error("invalid skills", zap.Error(err))
+				return nil, err
+			}
+			update.Skills = &skills
+		case "avatar":
+			avatar := req.GetAvatar()
+			update.Avatar = &avatar
+		default:
+			log.Error("unknown update mask path", zap.String("path", path))
+			return nil, status.Errorf(codes.InvalidArgument, "unknown update mask path: %s", path)
+		}
+	}
+
+	userInfo, err := s.service.UpdateUser(ctx, id, update)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		if errors.Is(err, storage.ErrInvalidSkills) {
+			log.Error("skill not in the skills list")
+			return nil, status.Error(codes.InvalidArgument, storage.ErrInvalidSkills.Error())
+		}
+		if errors.Is(err, handle.ErrReserved) {
+			log.Error("handle is reserved")
+			return nil, status.Error(codes.InvalidArgument, handle.ErrReserved.Error())
+		}
+		if errors.Is(err, storage.ErrHandleTaken) {
+			log.Error("handle is taken")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrHandleTaken.Error())
+		}
+		if errors.Is(err, service.ErrAvatarTooLarge) {
+			log.Error("avatar is too large")
+			return nil, status.Error(codes.InvalidArgument, service.ErrAvatarTooLarge.Error())
+		}
+		if errors.Is(err, service.ErrInvalidAvatar) {
+			log.Error("invalid avatar", zap.Error(err))
+			return nil, status.Error(codes.InvalidArgument, service.ErrInvalidAvatar.Error())
+		}
+		log.Error("failed to update user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to update user")
+	}
+
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
+	return &user.UpdateUserResponse{
+		User: userType,
+	}, nil
+}
+
+func (s *Server) DeleteUser(ctx context.Context, req *user.DeleteUserRequest) (*emptypb.Empty, error) {
+	const op = "grpc.server.DeleteUser"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	id, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	err = s.service.DeleteUser(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, storage.ErrUserNotFound.Error())
+		}
+		log.Error("failed to delete user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to delete user")
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RestoreUser(ctx context.Context, req *user.RestoreUserRequest) (*user.RestoreUserResponse, error) {
+	const op = "grpc.server.RestoreUser"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if req.GetId() == "" {
+		log.Error("id is empty")
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	// the owner restores the account, admins restore any
+	if err := requireAdmin(ctx); err != nil {
+		id, err := userIdFromMetadata(ctx)
+		if err != nil {
+			log.Error("failed to get user id", zap.Error(err))
+			return nil, err
+		}
+		if id != req.GetId() {
+			log.Error("restoring another user", zap.String("user_id", id))
+			return nil, status.Error(codes.PermissionDenied, "only the owner or an admin can restore the user")
+		}
+	}
+
+	userInfo, err := s.service.RestoreUser(ctx, req.GetId())
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Error("deleted user not found", zap.Error(err))
+			return nil, status.Error(codes.NotFound, "deleted user not found")
+		}
+		log.Error("failed to restore user", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to restore user")
+	}
+
+	userType, err := s.toUserType(ctx, userInfo)
+	if err != nil {
+		log.Error("failed to get avatar urls", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get avatar urls")
+	}
+
+	return &user.RestoreUserResponse{
+		User: userType,
 	}, nil
 }
 
@@ -258,46 +690,138 @@ func (s *Server) GetUsersBySkills(ctx context.Context, req *user.GetUsersBySkill
 
 	log := logger.FromCtx(ctx).With(zap.String("op", op))
 
+	userId, err := userIdFromMetadata(ctx)
+	if err != nil {
+		log.Error("failed to get user id", zap.Error(err))
+		return nil, err
+	}
+
+	if req.GetPageSize() < 0 {
+		log.Error("page size is negative")
+		return nil, status.Error(codes.InvalidArgument, "page size can't be negative")
+	}
+	if req.GetMinLevel() < consts.SkillLevelUnspecified || req.GetMinLevel() > consts.SkillLevelLead {
+		log.Error("invalid min level", zap.Int32("min_level", int32(req.GetMinLevel())))
+		return nil, status.Error(codes.InvalidArgument, "invalid min level")
+	}
+	for skill, weight := range req.GetSkillWeights() {
+		if weight <= 0 {
+			log.Error("skill weight is not positive", zap.String("skill", skill))
+			return nil, status.Errorf(codes.InvalidArgument, "weight of skill %s must be positive", skill)
+		}
+	}
+
+	page, err := s.service.UsersBySkills(ctx, userId, models.UsersQuery{
+		Skills:            req.GetSkills(),
+		SkillWeights:      req.GetSkillWeights(),
+		MinLevel:          int(req.GetMinLevel()),
+		MatchAll:          req.GetMatchAll(),
+		ByRelevance:       req.GetSortByRelevance(),
+		PageSize:          int(req.GetPageSize()),
+		PageToken:         req.GetPageToken(),
+		Seed:              req.GetSeed(),
+		IncludeTotalCount: req.GetIncludeTotalCount(),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPageToken) {
+			log.Error("invalid page token")
+			return nil, status.Error(codes.InvalidArgument, service.ErrInvalidPageToken.Error())
+		}
+		log.Error("failed to get users", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get users")
+	}
+
+	users := make([]*user.UserType, 0, len(page.Users))
+	for _, match := range page.Users {
+		userType, err := s.toUserType(ctx, match.User)
+		if err != nil {
+			log.Error("failed to get avatar urls", zap.Error(err))
+			return nil, status.Error(codes.Internal, "failed to get avatar urls")
+		}
+		userType.MatchScore = &match.Score
+		users = append(users, userType)
+	}
+
+	return &user.GetUsersBySkillsResponse{
+		User:          users,
+		NextPageToken: page.NextPageToken,
+		TotalCount:    page.TotalCount,
+	}, nil
+}
+
+// userIdFromMetadata returns the id of the authenticated caller
+// which the gateway puts into the request metadata.
+func userIdFromMetadata(ctx context.Context) (string, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		log.Error("failed to get metadata")
-		return nil, status.Error(codes.Unauthenticated, "failed to get metadata")
+		return "", status.Error(codes.Unauthenticated, "failed to get metadata")
 	}
 
-	userId, ok := md["user_id"]
-	if !ok {
-		log.Error("failed to get user id")
-		return nil, status.Error(codes.Unauthenticated, "user id is required")
+	userId := md.Get("user_id")
+	if len(userId) == 0 || userId[0] == "" {
+		return "", status.Error(codes.Unauthenticated, "user id is required")
 	}
 
-	skills := req.GetSkills()
-	// if skills == nil || len(skills) == 0 {
-	// 	log.Error("skills is empty")
-	// 	return nil, status.Error(codes.InvalidArgument, "skills is required")
-	// }
+	return userId[0], nil
+}
 
-	usersInfo, err := s.service.UsersBySkills(ctx, userId[0], skills)
+// toUserType converts a user model to its transport representation with
+// freshly presigned avatar urls. The password hash never leaves the service.
+func (s *Server) toUserType(ctx context.Context, userInfo models.User) (*user.UserType, error) {
+	avatarUrls, err := s.service.AvatarUrls(ctx, userInfo)
 	if err != nil {
-		log.Error("failed to get users")
-		return nil, status.Error(codes.InvalidArgument, "failed to get users")
+		return nil, err
 	}
 
-	users := make([]*user.UserType, 0, len(usersInfo))
-	for _, userInfo := range usersInfo {
-		user := &user.UserType{
-			Id:              userInfo.ID,
-			Email:           userInfo.Email,
-			Name:            userInfo.Name,
-			Password:        userInfo.Password,
-			About:           &userInfo.About,
-			Skills:          userInfo.Skills,
-			AvatarUrl:       userInfo.AvatarUrl,
-			IsEmailVerified: userInfo.IsEmailVerified,
-		}
-		users = append(users, user)
+	skills := make([]string, 0, len(userInfo.Skills))
+	details := make([]*user.UserSkill, 0, len(userInfo.Skills))
+	for _, skill := range userInfo.Skills {
+		skills = append(skills, skill.Slug)
+		details = append(details, &user.UserSkill{
+			Slug:  skill.Slug,
+			Level: user.SkillLevel(skill.Level),
+			Years: int32(skill.Years),
+		})
 	}
 
-	return &user.GetUsersBySkillsResponse{
-		User: users,
+	return &user.UserType{
+		Id:               userInfo.ID,
+		Email:            userInfo.Email,
+		Name:             userInfo.Name,
+		Handle:           userInfo.Handle,
+		About:            &userInfo.About,
+		Skills:           skills,
+		SkillDetails:     details,
+		AvatarUrl:        avatarUrls.Avatar,
+		AvatarThumbnails: toAvatarThumbnails(avatarUrls.Thumbnails),
+		IsEmailVerified:  userInfo.IsEmailVerified,
 	}, nil
 }
+
+// normalizeEmail returns the canonical form of email or an InvalidArgument status.
+func normalizeEmail(email string) (string, error) {
+	normalized, err := mailaddr.Normalize(email)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return normalized, nil
+}
+
+func normalizeHandle(userHandle string) (string, error) {
+	normalized, err := handle.Normalize(userHandle)
+	if err != nil {
+		return "", status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return normalized, nil
+}
+
+func toAvatarThumbnails(thumbnails map[int]string) map[int32]string {
+	res := make(map[int32]string, len(thumbnails))
+	for size, url := range thumbnails {
+		res[int32(size)] = url
+	}
+
+	return res
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/AlexMickh/proj-protos/pkg/api/user"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/internal/validation"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9+#.\-]{0,49}$`)
+
+func (s *Server) ListSkills(ctx context.Context, req *user.ListSkillsRequest) (*user.ListSkillsResponse, error) {
+	const op = "grpc.server.ListSkills"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	skills, err := s.service.Skills(ctx, req.GetCategory(), req.GetIncludeDeprecated())
+	if err != nil {
+		log.Error("failed to get skills", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to get skills")
+	}
+
+	resp := make([]*user.SkillType, 0, len(skills))
+	for _, skill := range skills {
+		resp = append(resp, toSkillType(skill))
+	}
+
+	return &user.ListSkillsResponse{
+		Skills: resp,
+	}, nil
+}
+
+func (s *Server) AddSkill(ctx context.Context, req *user.AddSkillRequest) (*user.AddSkillResponse, error) {
+	const op = "grpc.server.AddSkill"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if err := requireAdmin(ctx); err != nil {
+		log.Error("caller is not an admin")
+		return nil, err
+	}
+
+	if !slugRegexp.MatchString(req.GetSlug()) {
+		log.Error("invalid slug", zap.String("slug", req.GetSlug()))
+		return nil, status.Error(codes.InvalidArgument, "slug must be 1-50 lowercase letters, digits or +#.-")
+	}
+	if req.GetDisplayName() == "" {
+		log.Error("display name is empty")
+		return nil, status.Error(codes.InvalidArgument, "display name is required")
+	}
+	if req.GetCategory() == "" {
+		log.Error("category is empty")
+		return nil, status.Error(codes.InvalidArgument, "category is required")
+	}
+
+	skill, err := s.service.AddSkill(ctx, req.GetSlug(), req.GetDisplayName(), req.GetCategory())
+	if err != nil {
+		if errors.Is(err, storage.ErrSkillAlreadyExists) {
+			log.Error("skill already exists")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrSkillAlreadyExists.Error())
+		}
+		log.Error("failed to add skill", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to add skill")
+	}
+
+	return &user.AddSkillResponse{
+		Skill: toSkillType(skill),
+	}, nil
+}
+
+func (s *Server) RenameSkill(ctx context.Context, req *user.RenameSkillRequest) (*user.RenameSkillResponse, error) {
+	const op = "grpc.server.RenameSkill"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if err := requireAdmin(ctx); err != nil {
+		log.Error("caller is not an admin")
+		return nil, err
+	}
+
+	if req.GetSlug() == "" {
+		log.Error("slug is empty")
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+	if req.GetNewSlug() == "" && req.GetDisplayName() == "" && req.GetCategory() == "" {
+		log.Error("nothing to rename")
+		return nil, status.Error(codes.InvalidArgument, "new slug, display name or category is required")
+	}
+
+	var update models.SkillUpdate
+	if req.GetNewSlug() != "" {
+		if !slugRegexp.MatchString(req.GetNewSlug()) {
+			log.Error("invalid slug", zap.String("slug", req.GetNewSlug()))
+			return nil, status.Error(codes.InvalidArgument, "slug must be 1-50 lowercase letters, digits or +#.-")
+		}
+		newSlug := req.GetNewSlug()
+		update.Slug = &newSlug
+	}
+	if req.GetDisplayName() != "" {
+		displayName := req.GetDisplayName()
+		update.DisplayName = &displayName
+	}
+	if req.GetCategory() != "" {
+		category := req.GetCategory()
+		update.Category = &category
+	}
+
+	skill, err := s.service.UpdateSkill(ctx, req.GetSlug(), update)
+	if err != nil {
+		if errors.Is(err, storage.ErrSkillNotFound) {
+			log.Error("skill not found")
+			return nil, status.Error(codes.NotFound, storage.ErrSkillNotFound.Error())
+		}
+		if errors.Is(err, storage.ErrSkillAlreadyExists) {
+			log.Error("skill already exists")
+			return nil, status.Error(codes.AlreadyExists, storage.ErrSkillAlreadyExists.Error())
+		}
+		log.Error("failed to rename skill", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to rename skill")
+	}
+
+	return &user.RenameSkillResponse{
+		Skill: toSkillType(skill),
+	}, nil
+}
+
+func (s *Server) DeprecateSkill(ctx context.Context, req *user.DeprecateSkillRequest) (*user.DeprecateSkillResponse, error) {
+	const op = "grpc.server.DeprecateSkill"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	if err := requireAdmin(ctx); err != nil {
+		log.Error("caller is not an admin")
+		return nil, err
+	}
+
+	if req.GetSlug() == "" {
+		log.Error("slug is empty")
+		return nil, status.Error(codes.InvalidArgument, "slug is required")
+	}
+
+	deprecated := req.GetDeprecated()
+	skill, err := s.service.UpdateSkill(ctx, req.GetSlug(), models.SkillUpdate{
+		IsDeprecated: &deprecated,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrSkillNotFound) {
+			log.Error("skill not found")
+			return nil, status.Error(codes.NotFound, storage.ErrSkillNotFound.Error())
+		}
+		log.Error("failed to deprecate skill", zap.Error(err))
+		return nil, status.Error(codes.Internal, "failed to deprecate skill")
+	}
+
+	return &user.DeprecateSkillResponse{
+		Skill: toSkillType(skill),
+	}, nil
+}
+
+// requireAdmin checks the role the gateway puts into the request metadata.
+func requireAdmin(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "failed to get metadata")
+	}
+
+	for _, role := range md.Get("role") {
+		if role == "admin" {
+			return nil
+		}
+	}
+
+	return status.Error(codes.PermissionDenied, "admin role is required")
+}
+
+// userSkills merges the plain skill slugs and the detailed skills of a request,
+// plain slugs get an unspecified level.
+func userSkills(slugs []string, details []*user.UserSkill) ([]models.UserSkill, error) {
+	skills := make([]models.UserSkill, 0, len(slugs)+len(details))
+	for _, slug := range slugs {
+		skills = append(skills, models.UserSkill{Slug: slug})
+	}
+	for _, detail := range details {
+		skills = append(skills, models.UserSkill{
+			Slug:  detail.GetSlug(),
+			Level: int(detail.GetLevel()),
+			Years: int(detail.GetYears()),
+		})
+	}
+
+	if err := validation.Skills(skills); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return skills, nil
+}
+
+func toSkillType(skill models.Skill) *user.SkillType {
+	return &user.SkillType{
+		Slug:         skill.Slug,
+		DisplayName:  skill.DisplayName,
+		Category:     skill.Category,
+		IsDeprecated: skill.IsDeprecated,
+	}
+}
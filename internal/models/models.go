@@ -1,12 +1,171 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
-	ID              string   `redis:"-"`
-	Email           string   `redis:"-"`
-	Name            string   `redis:"name"`
-	Password        string   `redis:"password"`
-	About           string   `redis:"about"`
-	Skills          []string `redis:"-"`
-	AvatarUrl       string   `redis:"avatar_url"`
-	IsEmailVerified bool     `redis:"is_email_verified"`
+	ID               string           `redis:"-"`
+	Email            string           `redis:"email"`
+	Name             string           `redis:"name"`
+	Handle           string           `redis:"handle"`
+	Password         string           `redis:"password"`
+	About            string           `redis:"about"`
+	Skills           []UserSkill      `redis:"-"`
+	AvatarKey        string           `redis:"avatar_key"`
+	AvatarThumbnails AvatarThumbnails `redis:"avatar_thumbnails"`
+	IsEmailVerified  bool             `redis:"is_email_verified"`
+	IsSuspended      bool             `redis:"is_suspended"`
+}
+
+// AvatarThumbnails maps the thumbnail size in pixels to its object key.
+// It is kept in the cache as a json string.
+type AvatarThumbnails map[int]string
+
+func (t AvatarThumbnails) MarshalBinary() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+func (t *AvatarThumbnails) ScanRedis(value string) error {
+	return json.Unmarshal([]byte(value), t)
+}
+
+// UserSkill is a skill of a user with the proficiency level,
+// one of the consts.SkillLevel values, and years of experience.
+type UserSkill struct {
+	Slug  string `json:"slug"`
+	Level int    `json:"level"`
+	Years int    `json:"years"`
+}
+
+// NewUser is a user to be created, Avatar carries the raw image.
+type NewUser struct {
+	Email    string
+	Name     string
+	Password string
+	About    string
+	Skills   []UserSkill
+	Avatar   []byte
+}
+
+// UserUpdate describes a partial profile update, nil fields are left untouched.
+// Avatar carries the raw image and is turned into AvatarKey and
+// AvatarThumbnails by the service, nil AvatarThumbnails are left untouched.
+type UserUpdate struct {
+	Name             *string
+	Handle           *string
+	About            *string
+	Skills           *[]UserSkill
+	Avatar           *[]byte
+	AvatarKey        *string
+	AvatarThumbnails AvatarThumbnails
+}
+
+// Identity links a user to the account with the Subject id at a provider.
+type Identity struct {
+	Provider  string
+	Subject   string
+	UserId    string
+	CreatedAt time.Time
+}
+
+// Provider is an identity provider users can sign up with.
+// TrustEmail tells whether the email it asserts counts as verified.
+type Provider struct {
+	Name       string
+	TrustEmail bool
+}
+
+// AvatarUrls are presigned urls of an avatar and its thumbnails by size.
+type AvatarUrls struct {
+	Avatar     string
+	Thumbnails map[int]string
+}
+
+// UsersQuery describes a page of the users listing. An empty Skills matches
+// every user, an empty Seed starts a new shuffle.
+//
+// A user scores the sum of SkillWeights of the requested skills they have,
+// skills without a weight count as 1. MatchAll keeps only users having every
+// requested skill, ByRelevance orders users by score before shuffling.
+// A skill counts only when the user has it at MinLevel or above.
+type UsersQuery struct {
+	Skills            []string
+	SkillWeights      map[string]float64
+	MinLevel          int
+	MatchAll          bool
+	ByRelevance       bool
+	PageSize          int
+	PageToken         string
+	Seed              string
+	IncludeTotalCount bool
+}
+
+type UserMatch struct {
+	User  User
+	Score float64
+}
+
+// UsersCursor points at the last user of a page. Seed fixes the shuffle
+// order, so every page of the same listing uses the same order.
+type UsersCursor struct {
+	Seed    string  `json:"s"`
+	Score   float64 `json:"sc,omitempty"`
+	SortKey string  `json:"k,omitempty"`
+	ID      string  `json:"i,omitempty"`
+}
+
+type UsersPage struct {
+	Users         []UserMatch
+	NextPageToken string
+	TotalCount    int64
+}
+
+// ExportedUser is a user as seen by bulk exports, without any secrets.
+type ExportedUser struct {
+	ID              string
+	Email           string
+	Name            string
+	Handle          string
+	About           string
+	Skills          []UserSkill
+	Provider        string
+	IsEmailVerified bool
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+// UserRecord is the full state of a user as seen by admins, without secrets.
+type UserRecord struct {
+	ExportedUser
+	AvatarKey   string
+	HasPassword bool
+	SuspendedAt *time.Time
+	DeletedAt   *time.Time
+}
+
+// UsersExportFilter narrows a users export, zero fields match every user.
+// CreatedFrom is inclusive and CreatedTo is exclusive.
+type UsersExportFilter struct {
+	Provider        string
+	IsEmailVerified *bool
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+}
+
+type Skill struct {
+	ID           int
+	Slug         string
+	DisplayName  string
+	Category     string
+	IsDeprecated bool
+}
+
+// SkillUpdate describes a partial skill update, nil fields are left untouched.
+type SkillUpdate struct {
+	Slug         *string
+	DisplayName  *string
+	Category     *string
+	IsDeprecated *bool
 }
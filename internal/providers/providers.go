@@ -0,0 +1,55 @@
+package providers
+
+import (
+	"strings"
+
+	"github.com/AlexMickh/proj-user/internal/consts"
+	"github.com/AlexMickh/proj-user/internal/models"
+)
+
+// defaults are used when no providers are configured. VK and GitLab
+// may return an email the user never confirmed, so it isn't trusted.
+var defaults = []models.Provider{
+	{Name: consts.YandexProvider, TrustEmail: true},
+	{Name: consts.GitHubProvider, TrustEmail: true},
+	{Name: consts.GoogleProvider, TrustEmail: true},
+	{Name: consts.GitLabProvider, TrustEmail: false},
+	{Name: consts.VKProvider, TrustEmail: false},
+}
+
+// Registry holds the providers users can sign up with besides the fields one.
+type Registry struct {
+	providers map[string]models.Provider
+	ordered   []models.Provider
+}
+
+func New(providers []models.Provider) *Registry {
+	if len(providers) == 0 {
+		providers = defaults
+	}
+
+	r := &Registry{
+		providers: make(map[string]models.Provider, len(providers)),
+		ordered:   make([]models.Provider, 0, len(providers)),
+	}
+	for _, provider := range providers {
+		provider.Name = strings.ToLower(provider.Name)
+		if _, ok := r.providers[provider.Name]; ok || provider.Name == consts.FieldProvider {
+			continue
+		}
+		r.providers[provider.Name] = provider
+		r.ordered = append(r.ordered, provider)
+	}
+
+	return r
+}
+
+// Provider looks a provider up by its case-insensitive name.
+func (r *Registry) Provider(name string) (models.Provider, bool) {
+	provider, ok := r.providers[strings.ToLower(name)]
+	return provider, ok
+}
+
+func (r *Registry) Providers() []models.Provider {
+	return r.ordered
+}
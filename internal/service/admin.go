@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/consts"
+	"github.com/AlexMickh/proj-user/internal/models"
+)
+
+// FindUsers looks users up by id, email or name for support requests,
+// deleted and unverified users are included.
+func (s *Service) FindUsers(ctx context.Context, search string, limit int) ([]models.UserRecord, error) {
+	const op = "service.FindUsers"
+
+	users, err := s.storage.FindUsers(ctx, search, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+func (s *Service) UserRecord(ctx context.Context, idOrEmail string) (models.UserRecord, error) {
+	const op = "service.UserRecord"
+
+	user, err := s.storage.UserRecord(ctx, idOrEmail)
+	if err != nil {
+		return models.UserRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// MarkEmailVerified verifies the email of the user without a token,
+// pending verification tokens are revoked.
+func (s *Service) MarkEmailVerified(ctx context.Context, id string) (models.User, error) {
+	const op = "service.MarkEmailVerified"
+
+	user, err := s.storage.VerifyEmail(ctx, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.storage.RevokeTokens(ctx, id, consts.EmailVerificationToken)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.UpdateUser(ctx, user)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SuspendUser blocks the password and provider logins of the user
+// and hides it from the users listing.
+func (s *Service) SuspendUser(ctx context.Context, id string) (models.User, error) {
+	const op = "service.SuspendUser"
+
+	user, err := s.storage.SuspendUser(ctx, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.UpdateUser(ctx, user)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Service) UnsuspendUser(ctx context.Context, id string) (models.User, error) {
+	const op = "service.UnsuspendUser"
+
+	user, err := s.storage.UnsuspendUser(ctx, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.UpdateUser(ctx, user)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// RecacheUser replaces the cached user with the one in the database.
+func (s *Service) RecacheUser(ctx context.Context, id string) (models.User, error) {
+	const op = "service.RecacheUser"
+
+	err := s.cash.DeleteUser(ctx, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UserById(ctx, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.SaveUser(ctx, user)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/pkg/avatar"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// orphansBatchSize limits the number of ids checked by a single query.
+const orphansBatchSize = 1000
+
+// UploadAvatar reads a new avatar of an existing user and stores it.
+// Reading is aborted with ErrAvatarTooLarge once it exceeds the configured size.
+func (s *Service) UploadAvatar(ctx context.Context, id string, avatar io.Reader) (models.User, error) {
+	const op = "service.UploadAvatar"
+
+	_, err := s.storage.UserById(ctx, id)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the whole image is needed to decode it and strip its metadata
+	data, err := io.ReadAll(&sizeLimitedReader{r: avatar, left: s.maxAvatarSize})
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(data) == 0 {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidAvatar)
+	}
+
+	avatarKey, thumbnails, err := s.saveAvatar(ctx, id, data)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UpdateUser(ctx, id, models.UserUpdate{
+		AvatarKey:        &avatarKey,
+		AvatarThumbnails: thumbnails,
+	})
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.UpdateUser(ctx, user)
+	if err != nil {
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// saveAvatar validates the avatar and stores it without metadata along with
+// its thumbnails and returns their object keys. An empty avatar falls back
+// to the default one for every size.
+func (s *Service) saveAvatar(ctx context.Context, id string, data []byte) (string, models.AvatarThumbnails, error) {
+	const op = "service.saveAvatar"
+
+	if len(data) == 0 {
+		key, err := s.s3.SaveAvatar(ctx, id, nil, "")
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		thumbnails := make(models.AvatarThumbnails, len(s.images.ThumbnailSizes()))
+		for _, size := range s.images.ThumbnailSizes() {
+			thumbnails[size] = key
+		}
+
+		return key, thumbnails, nil
+	}
+
+	if int64(len(data)) > s.maxAvatarSize {
+		return "", nil, fmt.Errorf("%s: %w", op, ErrAvatarTooLarge)
+	}
+
+	img, err := s.images.Process(data)
+	if err != nil {
+		if errors.Is(err, avatar.ErrTooLarge) {
+			return "", nil, fmt.Errorf("%s: %w", op, ErrAvatarTooLarge)
+		}
+		if errors.Is(err, avatar.ErrUnsupportedFormat) || errors.Is(err, avatar.ErrInvalidImage) {
+			return "", nil, fmt.Errorf("%s: %w", op, ErrInvalidAvatar)
+		}
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resized, err := s.images.Thumbnails(img)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	key, err := s.s3.SaveAvatar(ctx, id, img.Data, img.ContentType)
+	if err != nil {
+		return "", nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	thumbnails := make(models.AvatarThumbnails, len(resized))
+	for size, thumbnail := range resized {
+		thumbnails[size], err = s.s3.SaveThumbnail(ctx, id, size, thumbnail.Data, thumbnail.ContentType)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return key, thumbnails, nil
+}
+
+// AvatarUrls presigns the avatar and thumbnails of the user. The urls are
+// cached, so a profile keeps the same links while they are valid.
+func (s *Service) AvatarUrls(ctx context.Context, user models.User) (models.AvatarUrls, error) {
+	const op = "service.AvatarUrls"
+
+	sizes := make([]int, 0, len(user.AvatarThumbnails))
+	keys := []string{user.AvatarKey}
+	for size, key := range user.AvatarThumbnails {
+		sizes = append(sizes, size)
+		keys = append(keys, key)
+	}
+
+	urls, err := s.cash.AvatarUrls(ctx, keys)
+	if err != nil {
+		logger.FromCtx(ctx).Warn("failed to get cached avatar urls", zap.String("op", op), zap.Error(err))
+		urls = make([]string, len(keys))
+	}
+
+	presigned := make(map[string]string)
+	for i, key := range keys {
+		if urls[i] != "" {
+			continue
+		}
+
+		url, ok := presigned[key]
+		if !ok {
+			url, err = s.s3.GetImageUrl(ctx, key)
+			if err != nil {
+				return models.AvatarUrls{}, fmt.Errorf("%s: %w", op, err)
+			}
+			presigned[key] = url
+		}
+		urls[i] = url
+	}
+
+	if len(presigned) > 0 {
+		err = s.cash.SaveAvatarUrls(ctx, presigned)
+		if err != nil {
+			logger.FromCtx(ctx).Warn("failed to cache avatar urls", zap.String("op", op), zap.Error(err))
+		}
+	}
+
+	avatarUrls := models.AvatarUrls{
+		Avatar:     urls[0],
+		Thumbnails: make(map[int]string, len(sizes)),
+	}
+	for i, size := range sizes {
+		avatarUrls.Thumbnails[size] = urls[i+1]
+	}
+
+	return avatarUrls, nil
+}
+
+// sizeLimitedReader fails the read that goes past the limit,
+// unlike io.LimitReader which silently truncates the data.
+type sizeLimitedReader struct {
+	r    io.Reader
+	left int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, ErrAvatarTooLarge
+	}
+
+	return n, err
+}
+
+// removeAvatar compensates an avatar upload of a user that failed to be created.
+// It runs even if ctx is canceled, objects it fails to remove are left
+// to DeleteOrphanedAvatars.
+func (s *Service) removeAvatar(ctx context.Context, id string) {
+	const op = "service.removeAvatar"
+
+	err := s.s3.DeleteAvatar(context.WithoutCancel(ctx), id)
+	if err != nil {
+		logger.FromCtx(ctx).Warn("failed to remove avatar", zap.String("op", op), zap.Error(err))
+	}
+}
+
+// dropAvatar removes the avatar objects of a user whose avatar was reset
+// and the cached urls of them. Failures are only logged, as the user
+// already points to the default avatar.
+func (s *Service) dropAvatar(ctx context.Context, user models.User) {
+	const op = "service.dropAvatar"
+
+	log := logger.FromCtx(ctx).With(zap.String("op", op))
+
+	err := s.s3.DeleteAvatar(context.WithoutCancel(ctx), user.ID)
+	if err != nil {
+		log.Warn("failed to remove avatar", zap.Error(err))
+	}
+
+	keys := []string{user.AvatarKey}
+	for _, key := range user.AvatarThumbnails {
+		keys = append(keys, key)
+	}
+
+	err = s.cash.DeleteAvatarUrls(ctx, keys)
+	if err != nil {
+		log.Warn("failed to drop cached avatar urls", zap.Error(err))
+	}
+}
+
+// OrphanedAvatars returns the ids of avatar owners which have no user row.
+// Objects younger than the orphan min age are skipped, as their user
+// may be in the middle of creation.
+func (s *Service) OrphanedAvatars(ctx context.Context) ([]string, error) {
+	const op = "service.OrphanedAvatars"
+
+	owners, err := s.s3.AvatarOwners(ctx, time.Now().Add(-s.orphanMinAge))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var orphans []string
+	for batch := range slices.Chunk(owners, orphansBatchSize) {
+		existing, err := s.storage.ExistingUserIds(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		found := make(map[string]bool, len(existing))
+		for _, id := range existing {
+			found[id] = true
+		}
+
+		for _, id := range batch {
+			if !found[id] {
+				orphans = append(orphans, id)
+			}
+		}
+	}
+
+	return orphans, nil
+}
+
+// DeleteOrphanedAvatars removes the avatars left by failed user creations
+// and returns the number of removed avatars.
+func (s *Service) DeleteOrphanedAvatars(ctx context.Context) (int, error) {
+	const op = "service.DeleteOrphanedAvatars"
+
+	orphans, err := s.OrphanedAvatars(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var errs []error
+	deleted := 0
+	for _, id := range orphans {
+		if err := s.s3.DeleteAvatar(ctx, id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted++
+	}
+	if len(errs) > 0 {
+		return deleted, fmt.Errorf("%s: %w", op, errors.Join(errs...))
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/consts"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+	"github.com/AlexMickh/proj-user/pkg/utils/token"
+)
+
+// RequestEmailChange keeps the new email as pending and returns the token
+// confirming it, the current email stays in use until then.
+func (s *Service) RequestEmailChange(ctx context.Context, id string, email string) (string, error) {
+	const op = "service.RequestEmailChange"
+
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UserById(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Email == email {
+		return "", fmt.Errorf("%s: %w", op, ErrSameEmail)
+	}
+
+	exists, err := s.storage.EmailExists(ctx, email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if exists {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrUserAlreadyExists)
+	}
+
+	issued, err := s.storage.TokenIssuedWithin(ctx, id, consts.EmailChangeToken, s.resendInterval)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if issued {
+		return "", fmt.Errorf("%s: %w", op, ErrTooManyRequests)
+	}
+
+	err = s.storage.SetPendingEmail(ctx, id, email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the previous tokens are revoked, so only the latest email can be confirmed
+	changeToken, err := s.issueToken(ctx, id, consts.EmailChangeToken, s.verificationTTL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return changeToken, nil
+}
+
+// ConfirmEmailChange switches the user to the pending email.
+func (s *Service) ConfirmEmailChange(ctx context.Context, changeToken string) error {
+	const op = "service.ConfirmEmailChange"
+
+	id, err := s.storage.UseToken(ctx, token.Hash(changeToken), consts.EmailChangeToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	previous, err := s.storage.UserById(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.ConfirmEmailChange(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// tokens sent to the old address must not outlive the change
+	for _, kind := range []string{consts.PasswordResetToken, consts.EmailVerificationToken} {
+		err = s.storage.RevokeTokens(ctx, id, kind)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	err = s.cash.DeleteUser(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the index of the old email may outlive the cached user
+	err = s.cash.DeleteEmail(ctx, previous.Email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.SaveUser(ctx, user)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"github.com/AlexMickh/proj-user/pkg/utils/handle"
+	"go.uber.org/zap"
+)
+
+const handleSuggestions = 5
+
+// CheckHandleAvailable reports whether the handle is free, a few free
+// handles derived from it are suggested when it isn't.
+func (s *Service) CheckHandleAvailable(ctx context.Context, userHandle string) (bool, []string, error) {
+	const op = "service.CheckHandleAvailable"
+
+	userHandle, err := handle.Normalize(userHandle)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !handle.IsReserved(userHandle) {
+		taken, err := s.storage.TakenHandles(ctx, []string{userHandle})
+		if err != nil {
+			return false, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		if len(taken) == 0 {
+			return true, nil, nil
+		}
+	}
+
+	// twice as many candidates, so a few taken ones don't shrink the list
+	candidates := handle.Candidates(userHandle, handleSuggestions*2)
+	taken, err := s.storage.TakenHandles(ctx, candidates)
+	if err != nil {
+		return false, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	suggestions := make([]string, 0, handleSuggestions)
+	for _, candidate := range candidates {
+		if slices.Contains(taken, candidate) {
+			continue
+		}
+		suggestions = append(suggestions, candidate)
+		if len(suggestions) == handleSuggestions {
+			break
+		}
+	}
+
+	return false, suggestions, nil
+}
+
+func (s *Service) UserByHandle(ctx context.Context, userHandle string) (models.User, error) {
+	const op = "service.UserByHandle"
+
+	userHandle, err := handle.Normalize(userHandle)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UserByHandle(ctx, userHandle)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !user.IsEmailVerified {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerify)
+	}
+
+	err = s.cash.SaveUser(ctx, user)
+	if err != nil {
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// seedHandle picks a free handle for a new user from the provider login.
+// The user is left without a handle when the login doesn't make one or
+// no free handle is found, it can be set later.
+func (s *Service) seedHandle(ctx context.Context, login string) string {
+	const op = "service.seedHandle"
+
+	userHandle := handle.FromLogin(login)
+	if userHandle == "" {
+		return ""
+	}
+
+	available, suggestions, err := s.CheckHandleAvailable(ctx, userHandle)
+	if err != nil {
+		logger.FromCtx(ctx).Warn("failed to check handle", zap.String("op", op), zap.Error(err))
+		return ""
+	}
+	if available {
+		return userHandle
+	}
+	if len(suggestions) > 0 {
+		return suggestions[0]
+	}
+
+	return ""
+}
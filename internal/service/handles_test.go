@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/utils/handle"
+)
+
+type handleStorage struct {
+	Storage
+	updated bool
+}
+
+func (s *handleStorage) UpdateUser(ctx context.Context, id string, update models.UserUpdate) (models.User, error) {
+	s.updated = true
+	return models.User{}, fmt.Errorf("storage.postgres.UpdateUser: %w", storage.ErrHandleTaken)
+}
+
+func TestUpdateUserHandle(t *testing.T) {
+	tests := []struct {
+		name        string
+		handle      string
+		wantErr     error
+		wantUpdated bool
+	}{
+		{name: "reserved", handle: "admin", wantErr: handle.ErrReserved, wantUpdated: false},
+		{name: "taken", handle: "alex_m", wantErr: storage.ErrHandleTaken, wantUpdated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := &handleStorage{}
+			s := New(st, nil, nil, nil, nil, nil, 0, 0, 0, 0, 0, 0)
+
+			_, err := s.UpdateUser(context.Background(), "id", models.UserUpdate{Handle: &tt.handle})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if st.updated != tt.wantUpdated {
+				t.Errorf("storage updated = %t, want %t", st.updated, tt.wantUpdated)
+			}
+		})
+	}
+}
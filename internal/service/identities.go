@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+)
+
+// CreateUserWithProvider returns the id of the user owning the provider
+// account, the user is created on the first login with a handle seeded from
+// the login. An existing account with the same email is never matched, it
+// has to link the provider itself.
+func (s *Service) CreateUserWithProvider(
+	ctx context.Context,
+	provider string,
+	subject string,
+	email string,
+	login string,
+) (string, string, error) {
+	const op = "service.CreateUserWithProvider"
+
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	p, ok := s.providers.Provider(provider)
+	if !ok {
+		return "", "", fmt.Errorf("%s: %w", op, ErrUnknownProvider)
+	}
+
+	id, err := s.storage.UserIdByIdentity(ctx, p.Name, subject)
+	if err == nil {
+		return id, "", nil
+	}
+	if !errors.Is(err, storage.ErrIdentityNotFound) {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity := models.Identity{
+		Provider: p.Name,
+		Subject:  subject,
+	}
+
+	userHandle := s.seedHandle(ctx, login)
+	id, verificationToken, err := s.createUser(ctx, p.Name, &identity, email, login, userHandle, "", "", nil, nil)
+	if errors.Is(err, storage.ErrHandleTaken) {
+		// taken by a concurrent sign up, the user can pick another one later
+		id, verificationToken, err = s.createUser(ctx, p.Name, &identity, email, login, "", "", "", nil, nil)
+	}
+	if err != nil {
+		if !errors.Is(err, storage.ErrUserAlreadyExists) {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		// accounts created by the provider before identities were stored
+		id, err = s.storage.LinkLegacyIdentity(ctx, email, p.Name, subject)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return id, "", nil
+	}
+
+	return id, verificationToken, nil
+}
+
+// LinkIdentity adds one more provider account the user can log in with.
+func (s *Service) LinkIdentity(ctx context.Context, userId string, provider string, subject string) (models.Identity, error) {
+	const op = "service.LinkIdentity"
+
+	p, ok := s.providers.Provider(provider)
+	if !ok {
+		return models.Identity{}, fmt.Errorf("%s: %w", op, ErrUnknownProvider)
+	}
+
+	identity, err := s.storage.LinkIdentity(ctx, models.Identity{
+		Provider: p.Name,
+		Subject:  subject,
+		UserId:   userId,
+	})
+	if err != nil {
+		return models.Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+// UnlinkIdentity doesn't consult the registry, so identities of
+// providers removed from the configuration can be unlinked too.
+func (s *Service) UnlinkIdentity(ctx context.Context, userId string, provider string, subject string) error {
+	const op = "service.UnlinkIdentity"
+
+	err := s.storage.UnlinkIdentity(ctx, userId, strings.ToLower(provider), subject)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) Identities(ctx context.Context, userId string) ([]models.Identity, error) {
+	const op = "service.Identities"
+
+	identities, err := s.storage.Identities(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identities, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/consts"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+	"github.com/google/uuid"
+)
+
+// ImportUsers creates a batch of users signed up with provider. Passwords and
+// avatars are handled like in CreateUser, but no verification tokens are
+// issued: imported users are verified when verified is set or the provider
+// is trusted, the others have to request a token themselves.
+//
+// The returned errors hold the reason each user wasn't created and are nil
+// for the created users.
+func (s *Service) ImportUsers(
+	ctx context.Context,
+	provider string,
+	verified bool,
+	users []models.NewUser,
+) ([]error, error) {
+	const op = "service.ImportUsers"
+
+	if provider != consts.FieldProvider {
+		p, ok := s.providers.Provider(provider)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", op, ErrUnknownProvider)
+		}
+		provider = p.Name
+		verified = verified || p.TrustEmail
+	}
+
+	errs := make([]error, len(users))
+	batch := make([]models.User, 0, len(users))
+	indexes := make([]int, 0, len(users))
+	for i, newUser := range users {
+		user, err := s.prepareUser(ctx, newUser, verified)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		batch = append(batch, user)
+		indexes = append(indexes, i)
+	}
+
+	if len(batch) == 0 {
+		return errs, nil
+	}
+
+	batchErrs, err := s.storage.ImportUsers(ctx, batch, provider)
+	if err != nil {
+		for _, user := range batch {
+			s.removeAvatar(ctx, user.ID)
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, err := range batchErrs {
+		if err != nil {
+			s.removeAvatar(ctx, batch[i].ID)
+			errs[indexes[i]] = err
+		}
+	}
+
+	return errs, nil
+}
+
+// prepareUser hashes the password and stores the avatar of a user to import.
+// Users of oauth providers may come without a password, they get none.
+func (s *Service) prepareUser(ctx context.Context, newUser models.NewUser, verified bool) (models.User, error) {
+	const op = "service.prepareUser"
+
+	email, err := mailaddr.Normalize(newUser.Email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user := models.User{
+		ID:              uuid.NewString(),
+		Email:           email,
+		Name:            newUser.Name,
+		About:           newUser.About,
+		Skills:          newUser.Skills,
+		IsEmailVerified: verified,
+	}
+
+	if newUser.Password != "" {
+		hash, err := s.hasher.Hash(newUser.Password)
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		user.Password = hash
+	}
+
+	user.AvatarKey, user.AvatarThumbnails, err = s.saveAvatar(ctx, user.ID, newUser.Avatar)
+	if err != nil {
+		s.removeAvatar(ctx, user.ID)
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
@@ -2,13 +2,25 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/AlexMickh/proj-user/internal/consts"
 	"github.com/AlexMickh/proj-user/internal/models"
 	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/AlexMickh/proj-user/pkg/avatar"
+	"github.com/AlexMickh/proj-user/pkg/logger"
+	"github.com/AlexMickh/proj-user/pkg/utils/handle"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+	"github.com/AlexMickh/proj-user/pkg/utils/pagetoken"
+	"github.com/AlexMickh/proj-user/pkg/utils/token"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type Storage interface {
@@ -17,21 +29,65 @@ type Storage interface {
 		id string,
 		email string,
 		name string,
+		handle string,
 		password string,
 		about string,
-		skills []string,
-		avatarUrl string,
+		skills []models.UserSkill,
+		avatarKey string,
+		avatarThumbnails models.AvatarThumbnails,
 		provider string,
+		isEmailVerified bool,
+		identity *models.Identity,
 	) error
 	UserByEmail(ctx context.Context, email string) (models.User, error)
 	VerifyEmail(ctx context.Context, id string) (models.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+	SetPendingEmail(ctx context.Context, id string, email string) error
+	ConfirmEmailChange(ctx context.Context, id string) (models.User, error)
 	UserById(ctx context.Context, id string) (models.User, error)
-	UsersBySkills(ctx context.Context, userId string, skills []string) ([]models.User, error)
-	RandomUsers(ctx context.Context, userId string) ([]models.User, error)
+	UsersByIds(ctx context.Context, ids []string) ([]models.User, error)
+	UserByHandle(ctx context.Context, handle string) (models.User, error)
+	TakenHandles(ctx context.Context, handles []string) ([]string, error)
+	UsersBySkills(
+		ctx context.Context,
+		userId string,
+		search models.UsersQuery,
+		cursor models.UsersCursor,
+		limit int,
+	) ([]models.UserMatch, *models.UsersCursor, error)
+	CountUsersBySkills(ctx context.Context, userId string, search models.UsersQuery) (int64, error)
+	UpdateUser(ctx context.Context, id string, update models.UserUpdate) (models.User, error)
+	UpdatePassword(ctx context.Context, id string, password string) (models.User, error)
+	SoftDeleteUser(ctx context.Context, id string) (models.User, error)
+	RestoreUser(ctx context.Context, id string, gracePeriod time.Duration) (models.User, error)
+	PurgeDeletedUsers(ctx context.Context, gracePeriod time.Duration) ([]string, error)
+	ExistingUserIds(ctx context.Context, ids []string) ([]string, error)
+	ExportUsers(ctx context.Context, filter models.UsersExportFilter, fn func(models.ExportedUser) error) error
+	ImportUsers(ctx context.Context, users []models.User, provider string) ([]error, error)
+	FindUsers(ctx context.Context, search string, limit int) ([]models.UserRecord, error)
+	UserRecord(ctx context.Context, idOrEmail string) (models.UserRecord, error)
+	SuspendUser(ctx context.Context, id string) (models.User, error)
+	UnsuspendUser(ctx context.Context, id string) (models.User, error)
+	SaveToken(ctx context.Context, tokenHash string, userId string, kind string, ttl time.Duration) error
+	UseToken(ctx context.Context, tokenHash string, kind string) (string, error)
+	RevokeTokens(ctx context.Context, userId string, kind string) error
+	TokenIssuedWithin(ctx context.Context, userId string, kind string, interval time.Duration) (bool, error)
+	SaveSkill(ctx context.Context, slug string, displayName string, category string) (models.Skill, error)
+	UpdateSkill(ctx context.Context, slug string, update models.SkillUpdate) (models.Skill, error)
+	Skills(ctx context.Context, category string, includeDeprecated bool) ([]models.Skill, error)
+	UserIdByIdentity(ctx context.Context, provider string, subject string) (string, error)
+	LinkLegacyIdentity(ctx context.Context, email string, provider string, subject string) (string, error)
+	LinkIdentity(ctx context.Context, identity models.Identity) (models.Identity, error)
+	Identities(ctx context.Context, userId string) ([]models.Identity, error)
+	UnlinkIdentity(ctx context.Context, userId string, provider string, subject string) error
 }
 
 type S3 interface {
-	SaveAvatar(ctx context.Context, id string, avatar []byte) (string, error)
+	SaveAvatar(ctx context.Context, id string, avatar []byte, contentType string) (string, error)
+	SaveThumbnail(ctx context.Context, id string, size int, thumbnail []byte, contentType string) (string, error)
+	DeleteAvatar(ctx context.Context, id string) error
+	GetImageUrl(ctx context.Context, key string) (string, error)
+	AvatarOwners(ctx context.Context, modifiedBefore time.Time) ([]string, error)
 }
 
 type Cash interface {
@@ -39,21 +95,96 @@ type Cash interface {
 	UserByEmail(ctx context.Context, email string) (models.User, error)
 	UpdateUser(ctx context.Context, user models.User) error
 	UserById(ctx context.Context, id string) (models.User, error)
+	UsersByIds(ctx context.Context, ids []string) (map[string]models.User, error)
+	DeleteUser(ctx context.Context, id string) error
+	DeleteEmail(ctx context.Context, email string) error
+	AvatarUrls(ctx context.Context, keys []string) ([]string, error)
+	SaveAvatarUrls(ctx context.Context, urls map[string]string) error
+	DeleteAvatarUrls(ctx context.Context, keys []string) error
+}
+
+type Hasher interface {
+	Hash(password string) (string, error)
+	Compare(hash string, password string) (bool, error)
+	NeedsRehash(hash string) bool
+}
+
+type Providers interface {
+	Provider(name string) (models.Provider, bool)
+}
+
+type Images interface {
+	Process(data []byte) (avatar.Image, error)
+	Thumbnails(img avatar.Image) (map[int]avatar.Image, error)
+	ThumbnailSizes() []int
 }
 
 type Service struct {
-	storage Storage
-	s3      S3
-	cash    Cash
+	storage   Storage
+	s3        S3
+	cash      Cash
+	hasher    Hasher
+	images    Images
+	providers Providers
+
+	deletionGracePeriod time.Duration
+	verificationTTL     time.Duration
+	passwordResetTTL    time.Duration
+	resendInterval      time.Duration
+	maxAvatarSize       int64
+	orphanMinAge        time.Duration
+
+	dummyHash func() (string, error)
 }
 
-var ErrEmailNotVerify = errors.New("email is not verify")
+var (
+	ErrEmailNotVerify       = errors.New("email is not verify")
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrTooManyRequests      = errors.New("too many requests, try again later")
+	ErrPasswordNotSet       = errors.New("user signed up with a provider and has no password")
+	ErrInvalidPageToken     = errors.New("invalid page token")
+	ErrAvatarTooLarge       = errors.New("avatar is too large")
+	ErrInvalidAvatar        = errors.New("avatar is not a valid png, jpeg, webp or gif image")
+	ErrUnknownProvider      = errors.New("provider is not supported")
+	ErrSameEmail            = errors.New("new email is the current one")
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
 
-func New(storage Storage, s3 S3, cash Cash) *Service {
+func New(
+	storage Storage,
+	s3 S3,
+	cash Cash,
+	hasher Hasher,
+	images Images,
+	providers Providers,
+	deletionGracePeriod time.Duration,
+	verificationTTL time.Duration,
+	passwordResetTTL time.Duration,
+	resendInterval time.Duration,
+	maxAvatarSize int64,
+	orphanMinAge time.Duration,
+) *Service {
 	return &Service{
-		storage: storage,
-		s3:      s3,
-		cash:    cash,
+		storage:             storage,
+		s3:                  s3,
+		cash:                cash,
+		hasher:              hasher,
+		images:              images,
+		providers:           providers,
+		deletionGracePeriod: deletionGracePeriod,
+		verificationTTL:     verificationTTL,
+		passwordResetTTL:    passwordResetTTL,
+		resendInterval:      resendInterval,
+		maxAvatarSize:       maxAvatarSize,
+		orphanMinAge:        orphanMinAge,
+		dummyHash: sync.OnceValues(func() (string, error) {
+			return hasher.Hash("dummy password")
+		}),
 	}
 }
 
@@ -64,16 +195,64 @@ func (s *Service) CreateUser(
 	name string,
 	password string,
 	about string,
-	skills []string,
+	skills []models.UserSkill,
 	avatar []byte,
-) (string, error) {
+) (string, string, error) {
 	const op = "service.CreateUser"
 
+	id, verificationToken, err := s.createUser(ctx, provider, nil, email, name, "", password, about, skills, avatar)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, verificationToken, nil
+}
+
+// createUser creates a user and returns its id. A verification token is
+// returned too, unless the provider is trusted to have verified the email.
+func (s *Service) createUser(
+	ctx context.Context,
+	provider string,
+	identity *models.Identity,
+	email string,
+	name string,
+	handle string,
+	password string,
+	about string,
+	skills []models.UserSkill,
+	avatar []byte,
+) (string, string, error) {
+	const op = "service.createUser"
+
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	isEmailVerified := false
+	if provider != consts.FieldProvider {
+		p, ok := s.providers.Provider(provider)
+		if !ok {
+			return "", "", fmt.Errorf("%s: %w", op, ErrUnknownProvider)
+		}
+		provider = p.Name
+		isEmailVerified = p.TrustEmail
+	}
+
 	id := uuid.NewString()
 
-	avatarUrl, err := s.s3.SaveAvatar(ctx, id, avatar)
+	if password != "" {
+		hash, err := s.hasher.Hash(password)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %w", op, err)
+		}
+		password = hash
+	}
+
+	avatarKey, thumbnails, err := s.saveAvatar(ctx, id, avatar)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		s.removeAvatar(ctx, id)
+		return "", "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	err = s.storage.SaveUser(
@@ -81,30 +260,42 @@ func (s *Service) CreateUser(
 		id,
 		email,
 		name,
+		handle,
 		password,
 		about,
 		skills,
-		avatarUrl,
+		avatarKey,
+		thumbnails,
 		provider,
+		isEmailVerified,
+		identity,
 	)
 	if err != nil {
-		if errors.Is(err, storage.ErrUserAlreadyExists) && provider != consts.FieldProvider {
-			user, err := s.UserByEmail(ctx, email)
-			if err != nil {
-				return "", fmt.Errorf("%s: %w", op, err)
-			}
+		// the user doesn't exist, so nothing else references the uploaded avatar
+		s.removeAvatar(ctx, id)
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
 
-			return user.ID, nil
-		}
-		return "", fmt.Errorf("%s: %w", op, err)
+	if isEmailVerified {
+		return id, "", nil
 	}
 
-	return id, nil
+	verificationToken, err := s.issueToken(ctx, id, consts.EmailVerificationToken, s.verificationTTL)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, verificationToken, nil
 }
 
 func (s *Service) UserByEmail(ctx context.Context, email string) (models.User, error) {
 	const op = "service.UserByEmail"
 
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
 	user, err := s.cash.UserByEmail(ctx, email)
 	if err == nil {
 		if !user.IsEmailVerified {
@@ -129,9 +320,126 @@ func (s *Service) UserByEmail(ctx context.Context, email string) (models.User, e
 	return user, nil
 }
 
-func (s *Service) VerifyEmail(ctx context.Context, id string) error {
+func (s *Service) VerifyCredentials(ctx context.Context, email string, password string) (models.User, error) {
+	const op = "service.VerifyCredentials"
+
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if len(password) > consts.MaxPasswordLength {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	user, err := s.storage.UserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			s.compareDummy(password)
+			return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Password == "" {
+		s.compareDummy(password)
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+
+	ok, err := s.hasher.Compare(user.Password, password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	}
+	if !user.IsEmailVerified {
+		return models.User{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerify)
+	}
+	if user.IsSuspended {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserSuspended)
+	}
+
+	if s.hasher.NeedsRehash(user.Password) {
+		if err := s.rehashPassword(ctx, user.ID, password); err != nil {
+			// the credentials are valid, so a failed rehash must not block the login
+			logger.FromCtx(ctx).Warn("failed to rehash password", zap.String("op", op), zap.Error(err))
+		}
+	}
+
+	return user, nil
+}
+
+// compareDummy takes as long as checking a password, so an unknown email
+// can't be told from a wrong password by the response time.
+func (s *Service) compareDummy(password string) {
+	hash, err := s.dummyHash()
+	if err == nil {
+		_, _ = s.hasher.Compare(hash, password)
+	}
+}
+
+func (s *Service) rehashPassword(ctx context.Context, id string, password string) error {
+	const op = "service.rehashPassword"
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UpdatePassword(ctx, id, hash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.UpdateUser(ctx, user)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// ResendVerification issues a new verification token and revokes the previous ones.
+// Tokens can't be requested more often than once per resend interval.
+func (s *Service) ResendVerification(ctx context.Context, email string) (string, error) {
+	const op = "service.ResendVerification"
+
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UserByEmail(ctx, email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if user.IsEmailVerified {
+		return "", fmt.Errorf("%s: %w", op, ErrEmailAlreadyVerified)
+	}
+
+	issued, err := s.storage.TokenIssuedWithin(ctx, user.ID, consts.EmailVerificationToken, s.resendInterval)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if issued {
+		return "", fmt.Errorf("%s: %w", op, ErrTooManyRequests)
+	}
+
+	verificationToken, err := s.issueToken(ctx, user.ID, consts.EmailVerificationToken, s.verificationTTL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return verificationToken, nil
+}
+
+func (s *Service) VerifyEmail(ctx context.Context, verificationToken string) error {
 	const op = "service.VerifyEmail"
 
+	id, err := s.storage.UseToken(ctx, token.Hash(verificationToken), consts.EmailVerificationToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	user, err := s.storage.VerifyEmail(ctx, id)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
@@ -145,6 +453,189 @@ func (s *Service) VerifyEmail(ctx context.Context, id string) error {
 	return nil
 }
 
+// RequestPasswordReset issues a password reset token for a user
+// that signed up with email and password.
+func (s *Service) RequestPasswordReset(ctx context.Context, email string) (string, error) {
+	const op = "service.RequestPasswordReset"
+
+	email, err := mailaddr.Normalize(email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := s.storage.UserByEmail(ctx, email)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if user.Password == "" {
+		return "", fmt.Errorf("%s: %w", op, ErrPasswordNotSet)
+	}
+
+	issued, err := s.storage.TokenIssuedWithin(ctx, user.ID, consts.PasswordResetToken, s.resendInterval)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if issued {
+		return "", fmt.Errorf("%s: %w", op, ErrTooManyRequests)
+	}
+
+	resetToken, err := s.issueToken(ctx, user.ID, consts.PasswordResetToken, s.passwordResetTTL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resetToken, nil
+}
+
+func (s *Service) ResetPassword(ctx context.Context, resetToken string, newPassword string) error {
+	const op = "service.ResetPassword"
+
+	id, err := s.storage.UseToken(ctx, token.Hash(resetToken), consts.PasswordResetToken)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	hash, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.storage.UpdatePassword(ctx, id, hash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	// the cached entry holds the old hash
+	err = s.cash.DeleteUser(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// issueToken revokes unused tokens of the same kind and returns a new one.
+// Only the token hash is stored.
+func (s *Service) issueToken(ctx context.Context, userId string, kind string, ttl time.Duration) (string, error) {
+	const op = "service.issueToken"
+
+	err := s.storage.RevokeTokens(ctx, userId, kind)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	raw, hash, err := token.Generate()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.storage.SaveToken(ctx, hash, userId, kind, ttl)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return raw, nil
+}
+
+func (s *Service) UpdateUser(ctx context.Context, id string, update models.UserUpdate) (models.User, error) {
+	const op = "service.UpdateUser"
+
+	if update.Handle != nil && handle.IsReserved(*update.Handle) {
+		return models.User{}, fmt.Errorf("%s: %w", op, handle.ErrReserved)
+	}
+
+	// the objects of a reset avatar are removed once the user points
+	// to the default one
+	var previous models.User
+	resetAvatar := update.Avatar != nil && len(*update.Avatar) == 0
+	if resetAvatar {
+		var err error
+		previous, err = s.storage.UserById(ctx, id)
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if update.Avatar != nil {
+		avatarKey, thumbnails, err := s.saveAvatar(ctx, id, *update.Avatar)
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		update.AvatarKey = &avatarKey
+		update.AvatarThumbnails = thumbnails
+	}
+
+	user, err := s.storage.UpdateUser(ctx, id, update)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if resetAvatar && previous.AvatarKey != user.AvatarKey {
+		s.dropAvatar(ctx, previous)
+	}
+
+	err = s.cash.UpdateUser(ctx, user)
+	if err != nil {
+		return user, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// DeleteUser soft deletes the user, it can be restored until the grace period ends.
+func (s *Service) DeleteUser(ctx context.Context, id string) error {
+	const op = "service.DeleteUser"
+
+	_, err := s.storage.SoftDeleteUser(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.cash.DeleteUser(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) RestoreUser(ctx context.Context, id string) (models.User, error) {
+	const op = "service.RestoreUser"
+
+	user, err := s.storage.RestoreUser(ctx, id, s.deletionGracePeriod)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// PurgeDeletedUsers permanently removes users whose grace period is over
+// together with their avatars and returns the number of purged users.
+func (s *Service) PurgeDeletedUsers(ctx context.Context) (int, error) {
+	const op = "service.PurgeDeletedUsers"
+
+	ids, err := s.storage.PurgeDeletedUsers(ctx, s.deletionGracePeriod)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var errs []error
+	for _, id := range ids {
+		if err := s.s3.DeleteAvatar(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+		if err := s.cash.DeleteUser(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return len(ids), fmt.Errorf("%s: %w", op, errors.Join(errs...))
+	}
+
+	return len(ids), nil
+}
+
 func (s *Service) UserById(ctx context.Context, id string) (models.User, error) {
 	const op = "service.UserById"
 
@@ -173,22 +664,140 @@ func (s *Service) UserById(ctx context.Context, id string) (models.User, error)
 	return user, nil
 }
 
-func (s *Service) UsersBySkills(ctx context.Context, userId string, skills []string) ([]models.User, error) {
+// UsersByIds resolves many users at once, reading the cache first and the
+// database for the misses. Users are returned in the order of ids, the ids of
+// missing or unverified users are returned separately.
+func (s *Service) UsersByIds(ctx context.Context, ids []string) ([]models.User, []string, error) {
+	const op = "service.UsersByIds"
+
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	found, err := s.cash.UsersByIds(ctx, unique)
+	if err != nil {
+		logger.FromCtx(ctx).Warn("failed to get cached users", zap.String("op", op), zap.Error(err))
+		found = make(map[string]models.User)
+	}
+
+	var misses []string
+	for _, id := range unique {
+		if _, ok := found[id]; !ok {
+			misses = append(misses, id)
+		}
+	}
+
+	if len(misses) > 0 {
+		users, err := s.storage.UsersByIds(ctx, misses)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, user := range users {
+			found[user.ID] = user
+
+			err = s.cash.SaveUser(ctx, user)
+			if err != nil {
+				logger.FromCtx(ctx).Warn("failed to cache user", zap.String("op", op), zap.Error(err))
+			}
+		}
+	}
+
+	users := make([]models.User, 0, len(unique))
+	var notFound []string
+	for _, id := range unique {
+		user, ok := found[id]
+		if !ok || !user.IsEmailVerified {
+			notFound = append(notFound, id)
+			continue
+		}
+		users = append(users, user)
+	}
+
+	return users, notFound, nil
+}
+
+func (s *Service) UsersBySkills(ctx context.Context, userId string, query models.UsersQuery) (models.UsersPage, error) {
 	const op = "service.UsersBySkills"
 
-	if skills != nil {
-		users, err := s.storage.UsersBySkills(ctx, userId, skills)
+	pageSize := query.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	query.Skills = slices.Compact(slices.Sorted(slices.Values(query.Skills)))
+
+	var cursor models.UsersCursor
+	if query.PageToken != "" {
+		if err := pagetoken.Decode(query.PageToken, &cursor); err != nil {
+			return models.UsersPage{}, fmt.Errorf("%s: %w", op, ErrInvalidPageToken)
+		}
+	} else {
+		cursor.Seed = query.Seed
+		if cursor.Seed == "" {
+			seed, err := newSeed()
+			if err != nil {
+				return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
+			}
+			cursor.Seed = seed
+		}
+	}
+
+	users, next, err := s.storage.UsersBySkills(ctx, userId, query, cursor, pageSize)
+	if err != nil {
+		return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	page := models.UsersPage{
+		Users: users,
+	}
+
+	if next != nil {
+		page.NextPageToken, err = pagetoken.Encode(next)
 		if err != nil {
-			return nil, err
+			return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
 		}
+	}
 
-		return users, nil
+	if query.IncludeTotalCount {
+		page.TotalCount, err = s.storage.CountUsersBySkills(ctx, userId, query)
+		if err != nil {
+			return models.UsersPage{}, fmt.Errorf("%s: %w", op, err)
+		}
 	}
 
-	users, err := s.storage.RandomUsers(ctx, userId)
+	return page, nil
+}
+
+// ExportUsers streams every user matching filter to fn, see Storage.ExportUsers.
+func (s *Service) ExportUsers(
+	ctx context.Context,
+	filter models.UsersExportFilter,
+	fn func(models.ExportedUser) error,
+) error {
+	const op = "service.ExportUsers"
+
+	err := s.storage.ExportUsers(ctx, filter, fn)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func newSeed() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
 	}
 
-	return users, nil
+	return hex.EncodeToString(buf), nil
 }
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+)
+
+func (s *Service) AddSkill(ctx context.Context, slug string, displayName string, category string) (models.Skill, error) {
+	const op = "service.AddSkill"
+
+	skill, err := s.storage.SaveSkill(ctx, slug, displayName, category)
+	if err != nil {
+		return models.Skill{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skill, nil
+}
+
+// UpdateSkill renames, recategorizes or deprecates a skill. Deprecated skills
+// stay on the profiles that have them but can't be added anymore.
+// Cached profiles show a renamed slug only after their entry expires.
+func (s *Service) UpdateSkill(ctx context.Context, slug string, update models.SkillUpdate) (models.Skill, error) {
+	const op = "service.UpdateSkill"
+
+	skill, err := s.storage.UpdateSkill(ctx, slug, update)
+	if err != nil {
+		return models.Skill{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skill, nil
+}
+
+func (s *Service) Skills(ctx context.Context, category string, includeDeprecated bool) ([]models.Skill, error) {
+	const op = "service.Skills"
+
+	skills, err := s.storage.Skills(ctx, category, includeDeprecated)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skills, nil
+}
@@ -3,67 +3,180 @@ package minio
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 )
 
 type Minio struct {
-	mc         *minio.Client
-	bucketName string
+	mc            *minio.Client
+	bucketName    string
+	urlExpiration time.Duration
 }
 
 const defaultImage = "avatar.png"
 
-func New(mc *minio.Client, bucketName string) *Minio {
+func New(mc *minio.Client, bucketName string, urlExpiration time.Duration) *Minio {
 	return &Minio{
-		mc:         mc,
-		bucketName: bucketName,
+		mc:            mc,
+		bucketName:    bucketName,
+		urlExpiration: urlExpiration,
 	}
 }
 
-func (m *Minio) SaveAvatar(ctx context.Context, id string, avatar []byte) (string, error) {
+// SaveAvatar stores the avatar under the user id and returns its object key,
+// an empty avatar gets the key of the default image.
+func (m *Minio) SaveAvatar(ctx context.Context, id string, avatar []byte, contentType string) (string, error) {
 	const op = "storage.minio.user.SaveAvatar"
 
-	if avatar == nil {
-		url, err := m.GetImageUrl(ctx, defaultImage)
-		if err != nil {
-			return "", fmt.Errorf("%s: %w", op, err)
-		}
+	if len(avatar) == 0 {
+		return defaultImage, nil
+	}
 
-		return url, nil
+	err := m.putImage(ctx, id, avatar, contentType)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	reader := bytes.NewReader(avatar)
+	return id, nil
+}
 
-	_, err := m.mc.PutObject(
-		ctx,
-		m.bucketName,
-		id,
-		reader,
-		int64(len(avatar)),
-		minio.PutObjectOptions{ContentType: "image/png"},
-	)
+// SaveThumbnail stores a resized avatar next to the original under "<id>_<size>"
+// and returns its object key.
+func (m *Minio) SaveThumbnail(
+	ctx context.Context,
+	id string,
+	size int,
+	thumbnail []byte,
+	contentType string,
+) (string, error) {
+	const op = "storage.minio.SaveThumbnail"
+
+	key := thumbnailKey(id, size)
+
+	err := m.putImage(ctx, key, thumbnail, contentType)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
-	url, err := m.GetImageUrl(ctx, id)
+	return key, nil
+}
+
+func (m *Minio) putImage(ctx context.Context, key string, image []byte, contentType string) error {
+	const op = "storage.minio.putImage"
+
+	_, err := m.mc.PutObject(
+		ctx,
+		m.bucketName,
+		key,
+		bytes.NewReader(image),
+		int64(len(image)),
+		minio.PutObjectOptions{ContentType: contentType},
+	)
 	if err != nil {
-		return "", fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	return url, nil
+	return nil
 }
 
-func (m *Minio) GetImageUrl(ctx context.Context, avatarId string) (string, error) {
+func (m *Minio) GetImageUrl(ctx context.Context, key string) (string, error) {
 	const op = "storage.minio.GetImage"
 
-	url, err := m.mc.PresignedGetObject(ctx, m.bucketName, avatarId, 5*24*time.Hour, nil)
+	url, err := m.mc.PresignedGetObject(ctx, m.bucketName, key, m.urlExpiration, nil)
 	if err != nil {
 		return "", fmt.Errorf("%s: %w", op, err)
 	}
 
 	return url.String(), nil
 }
+
+// DeleteAvatar removes the avatar together with its thumbnails.
+func (m *Minio) DeleteAvatar(ctx context.Context, id string) error {
+	const op = "storage.minio.DeleteAvatar"
+
+	// stops the listing goroutines when the removal ends early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// RemoveObjects ignores listing errors, so only the listed objects are
+	// passed on and the first error is kept
+	var listErr error
+	listed := make(chan struct{})
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(listed)
+		defer close(objects)
+
+		for object := range m.mc.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{Prefix: id}) {
+			if object.Err != nil {
+				if listErr == nil {
+					listErr = object.Err
+				}
+				continue
+			}
+
+			select {
+			case objects <- object:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// the errors channel has to be drained, otherwise the removal goroutine leaks
+	var errs []error
+	for err := range m.mc.RemoveObjects(ctx, m.bucketName, objects, minio.RemoveObjectsOptions{}) {
+		errs = append(errs, err.Err)
+	}
+
+	cancel()
+	<-listed
+	if listErr != nil {
+		return fmt.Errorf("%s: %w", op, listErr)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %w", op, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// AvatarOwners returns the ids of users having objects in the bucket which
+// were modified before the given time. Keys not owned by a user, like the
+// default image, are skipped.
+func (m *Minio) AvatarOwners(ctx context.Context, modifiedBefore time.Time) ([]string, error) {
+	const op = "storage.minio.AvatarOwners"
+
+	// stops the listing goroutine when returning early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	seen := make(map[string]bool)
+	var ids []string
+	for object := range m.mc.ListObjects(ctx, m.bucketName, minio.ListObjectsOptions{}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("%s: %w", op, object.Err)
+		}
+		if !object.LastModified.Before(modifiedBefore) {
+			continue
+		}
+
+		id, _, _ := strings.Cut(object.Key, "_")
+		if err := uuid.Validate(id); err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func thumbnailKey(id string, size int) string {
+	return fmt.Sprintf("%s_%d", id, size)
+}
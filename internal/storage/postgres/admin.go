@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var userRecordColumns = slices.Concat(exportColumns, []string{
+	"avatar_key",
+	"COALESCE(password, '') <> ''",
+	"suspended_at",
+	"deleted_at",
+})
+
+// FindUsers returns users, deleted ones included, whose id, email or handle
+// equals search or whose email or name contains it.
+func (s *Storage) FindUsers(ctx context.Context, search string, limit int) ([]models.UserRecord, error) {
+	const op = "storage.postgres.FindUsers"
+
+	pattern := "%" + escapeLike(search) + "%"
+	match := sq.Or{
+		sq.Expr("email = ?", search),
+		sq.Expr("handle = ?", search),
+		sq.Expr("email ILIKE ?", pattern),
+		sq.Expr("name ILIKE ?", pattern),
+	}
+	if uuid.Validate(search) == nil {
+		match = append(match, sq.Eq{"id": search})
+	}
+
+	query, args, err := s.psql.Select(userRecordColumns...).
+		From("users").
+		Where(match).
+		OrderBy("created_at", "id").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.UserRecord, error) {
+		return scanUserRecord(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// UserRecord returns the user with the given id or email, even a deleted one.
+func (s *Storage) UserRecord(ctx context.Context, idOrEmail string) (models.UserRecord, error) {
+	const op = "storage.postgres.UserRecord"
+
+	match := sq.Eq{"email": idOrEmail}
+	if uuid.Validate(idOrEmail) == nil {
+		match = sq.Eq{"id": idOrEmail}
+	}
+
+	query, args, err := s.psql.Select(userRecordColumns...).
+		From("users").
+		Where(match).
+		ToSql()
+	if err != nil {
+		return models.UserRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUserRecord(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.UserRecord{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.UserRecord{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// SuspendUser blocks the logins of the user until it is unsuspended.
+func (s *Storage) SuspendUser(ctx context.Context, id string) (models.User, error) {
+	const op = "storage.postgres.SuspendUser"
+
+	user, err := s.setSuspendedAt(ctx, id, sq.Expr("COALESCE(suspended_at, CURRENT_TIMESTAMP)"))
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UnsuspendUser(ctx context.Context, id string) (models.User, error) {
+	const op = "storage.postgres.UnsuspendUser"
+
+	user, err := s.setSuspendedAt(ctx, id, nil)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) setSuspendedAt(ctx context.Context, id string, value any) (models.User, error) {
+	const op = "storage.postgres.setSuspendedAt"
+
+	query, args, err := s.psql.Update("users").
+		Set("suspended_at", value).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Suffix(returningUser).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func scanUserRecord(row pgx.Row) (models.UserRecord, error) {
+	var user models.UserRecord
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Handle,
+		&user.About,
+		&user.Skills,
+		&user.Provider,
+		&user.IsEmailVerified,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.AvatarKey,
+		&user.HasPassword,
+		&user.SuspendedAt,
+		&user.DeletedAt,
+	)
+
+	return user, err
+}
@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+const exportBatchSize = 500
+
+var exportColumns = []string{
+	"id",
+	"email",
+	"name",
+	"COALESCE(handle, '')",
+	"about",
+	userSkillsColumn,
+	"COALESCE(provider, '')",
+	"is_email_verified",
+	"created_at",
+	"COALESCE(updated_at, created_at)",
+}
+
+// ExportUsers calls fn for every user matching filter, ordered by creation
+// time. Users are read through a cursor in batches, so memory use doesn't
+// grow with the number of users. An error returned by fn stops the export.
+func (s *Storage) ExportUsers(
+	ctx context.Context,
+	filter models.UsersExportFilter,
+	fn func(models.ExportedUser) error,
+) error {
+	const op = "storage.postgres.ExportUsers"
+
+	query, args, err := s.psql.Select(exportColumns...).
+		From("users").
+		Where(usersExportFilter(filter)).
+		OrderBy("created_at", "id").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// DECLARE can't be prepared, so the arguments are sent with the simple protocol
+	_, err = tx.Exec(
+		ctx,
+		"DECLARE export_users NO SCROLL CURSOR FOR "+query,
+		append([]any{pgx.QueryExecModeSimpleProtocol}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for {
+		rows, err := tx.Query(ctx, fmt.Sprintf("FETCH %d FROM export_users", exportBatchSize))
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.ExportedUser, error) {
+			return scanExportedUser(row)
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if len(users) < exportBatchSize {
+			break
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func usersExportFilter(filter models.UsersExportFilter) sq.And {
+	where := sq.And{sq.Expr("deleted_at IS NULL")}
+	if filter.Provider != "" {
+		where = append(where, sq.Eq{"provider": filter.Provider})
+	}
+	if filter.IsEmailVerified != nil {
+		where = append(where, sq.Eq{"is_email_verified": *filter.IsEmailVerified})
+	}
+	if !filter.CreatedFrom.IsZero() {
+		where = append(where, sq.GtOrEq{"created_at": filter.CreatedFrom})
+	}
+	if !filter.CreatedTo.IsZero() {
+		where = append(where, sq.Lt{"created_at": filter.CreatedTo})
+	}
+
+	return where
+}
+
+func scanExportedUser(row pgx.Row) (models.ExportedUser, error) {
+	var user models.ExportedUser
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Handle,
+		&user.About,
+		&user.Skills,
+		&user.Provider,
+		&user.IsEmailVerified,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	return user, err
+}
@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+const handleConstraint = "users_handle_key"
+
+func (s *Storage) UserByHandle(ctx context.Context, handle string) (models.User, error) {
+	const op = "storage.postgres.UserByHandle"
+
+	query, args, err := s.psql.Select(userColumns...).
+		From("users").
+		Where("handle = ? AND deleted_at IS NULL", handle).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// TakenHandles returns the handles among handles that belong to a user.
+// Handles of deleted users stay taken until the user is purged.
+func (s *Storage) TakenHandles(ctx context.Context, handles []string) ([]string, error) {
+	const op = "storage.postgres.TakenHandles"
+
+	query, args, err := s.psql.Select("lower(handle::text)").
+		From("users").
+		Where("handle = ANY(?::text[]::citext[])", handles).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	taken, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return taken, nil
+}
+
+// nullableHandle stores an empty handle as NULL, so any number
+// of users can go without one.
+func nullableHandle(handle string) sq.Sqlizer {
+	return sq.Expr("NULLIF(?, '')", handle)
+}
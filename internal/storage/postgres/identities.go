@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var identityColumns = []string{"provider", "provider_subject", "user_id", "created_at"}
+
+var returningIdentity = "RETURNING " + strings.Join(identityColumns, ", ")
+
+// linkLegacyIdentity links the identity to the user which signed up with the
+// same provider before identities were stored. Any other account with the
+// email has to link the provider explicitly.
+const linkLegacyIdentity = `INSERT INTO user_identities (provider, provider_subject, user_id)
+SELECT $1, $2, u.id
+FROM users u
+WHERE u.email = $3 AND u.provider = $1 AND u.deleted_at IS NULL AND u.suspended_at IS NULL
+AND NOT EXISTS (SELECT 1 FROM user_identities i WHERE i.user_id = u.id AND i.provider = $1)
+RETURNING user_id`
+
+// UserIdByIdentity returns the id of the user the identity belongs to,
+// suspended users fail with storage.ErrUserSuspended.
+func (s *Storage) UserIdByIdentity(ctx context.Context, provider string, subject string) (string, error) {
+	const op = "storage.postgres.UserIdByIdentity"
+
+	query, args, err := s.psql.Select("i.user_id", "u.suspended_at IS NOT NULL").
+		From("user_identities i").
+		Join("users u ON u.id = i.user_id").
+		Where("i.provider = ? AND i.provider_subject = ? AND u.deleted_at IS NULL", provider, subject).
+		ToSql()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var userId string
+	var isSuspended bool
+	err = s.db.QueryRow(ctx, query, args...).Scan(&userId, &isSuspended)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrIdentityNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if isSuspended {
+		return "", fmt.Errorf("%s: %w", op, storage.ErrUserSuspended)
+	}
+
+	return userId, nil
+}
+
+func (s *Storage) LinkLegacyIdentity(ctx context.Context, email string, provider string, subject string) (string, error) {
+	const op = "storage.postgres.LinkLegacyIdentity"
+
+	var userId string
+	err := s.db.QueryRow(ctx, linkLegacyIdentity, provider, subject, email).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrUserAlreadyExists)
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrIdentityLinked)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userId, nil
+}
+
+func (s *Storage) LinkIdentity(ctx context.Context, identity models.Identity) (models.Identity, error) {
+	const op = "storage.postgres.LinkIdentity"
+
+	identity, err := s.saveIdentity(ctx, s.db, identity)
+	if err != nil {
+		return models.Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+func (s *Storage) Identities(ctx context.Context, userId string) ([]models.Identity, error) {
+	const op = "storage.postgres.Identities"
+
+	query, args, err := s.psql.Select(identityColumns...).
+		From("user_identities").
+		Where("user_id = ?", userId).
+		OrderBy("created_at", "provider").
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identities, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Identity, error) {
+		return scanIdentity(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identities, nil
+}
+
+// UnlinkIdentity removes the identity of the user unless it is the last way
+// to log in, a password counts as one.
+func (s *Storage) UnlinkIdentity(ctx context.Context, userId string, provider string, subject string) error {
+	const op = "storage.postgres.UnlinkIdentity"
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	// the user row is locked, so concurrent unlinks can't remove every method
+	query, args, err := s.psql.Select(
+		"COALESCE(password, '') <> ''",
+		"(SELECT count(*) FROM user_identities i WHERE i.user_id = users.id)",
+	).
+		From("users").
+		Where("id = ? AND deleted_at IS NULL", userId).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var hasPassword bool
+	var identities int
+	err = tx.QueryRow(ctx, query, args...).Scan(&hasPassword, &identities)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if !hasPassword && identities <= 1 {
+		return fmt.Errorf("%s: %w", op, storage.ErrLastLoginMethod)
+	}
+
+	query, args, err = s.psql.Delete("user_identities").
+		Where("user_id = ? AND provider = ? AND provider_subject = ?", userId, provider, subject).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := tx.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrIdentityNotFound)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) saveIdentity(ctx context.Context, db Querier, identity models.Identity) (models.Identity, error) {
+	const op = "storage.postgres.saveIdentity"
+
+	query, args, err := s.psql.Insert("user_identities").
+		Columns("provider", "provider_subject", "user_id").
+		Values(identity.Provider, identity.Subject, identity.UserId).
+		Suffix(returningIdentity).
+		ToSql()
+	if err != nil {
+		return models.Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	identity, err = scanIdentity(db.QueryRow(ctx, query, args...))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505":
+				return models.Identity{}, fmt.Errorf("%s: %w", op, storage.ErrIdentityLinked)
+			case "23503":
+				return models.Identity{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			}
+		}
+		return models.Identity{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return identity, nil
+}
+
+func scanIdentity(row pgx.Row) (models.Identity, error) {
+	var identity models.Identity
+	err := row.Scan(&identity.Provider, &identity.Subject, &identity.UserId, &identity.CreatedAt)
+
+	return identity, err
+}
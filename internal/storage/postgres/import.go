@@ -0,0 +1,226 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const importUserSkills = `INSERT INTO user_skills (user_id, skill_id, level, years)
+SELECT q.user_id::uuid, s.id, q.level, q.years
+FROM unnest($1::text[], $2::text[], $3::int2[], $4::int2[]) AS q(user_id, slug, level, years)
+JOIN skills s ON s.slug = q.slug
+WHERE NOT s.is_deprecated`
+
+// ImportUsers inserts a batch of users signed up with provider in a single
+// transaction. Users with unknown or deprecated skills, a taken email or a
+// row the database rejects are skipped, the returned errors hold the reason
+// for each of them and are nil for the inserted users.
+func (s *Storage) ImportUsers(ctx context.Context, users []models.User, provider string) ([]error, error) {
+	const op = "storage.postgres.ImportUsers"
+
+	errs := make([]error, len(users))
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	known, err := s.activeSkills(ctx, tx, users)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var pending []int
+	for i, user := range users {
+		for _, skill := range user.Skills {
+			if !known[skill.Slug] {
+				errs[i] = storage.ErrInvalidSkills
+				break
+			}
+		}
+		if errs[i] == nil {
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return errs, nil
+	}
+
+	ids, err := s.insertUsers(ctx, tx, users, pending, provider)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		// a single bad row fails the whole statement,
+		// the users are inserted one by one to find it
+		ids = ids[:0]
+		for _, i := range pending {
+			id, err := s.insertUsers(ctx, tx, users, []int{i}, provider)
+			if err != nil {
+				if !errors.As(err, &pgErr) {
+					return nil, fmt.Errorf("%s: %w", op, err)
+				}
+				errs[i] = pgErr
+				continue
+			}
+			ids = append(ids, id...)
+		}
+	}
+
+	inserted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		inserted[id] = true
+	}
+
+	var userIds, slugs []string
+	var levels, years []int16
+	for i, user := range users {
+		if errs[i] != nil {
+			continue
+		}
+		// the only unique key a fresh id can hit is the email
+		if !inserted[user.ID] {
+			errs[i] = storage.ErrUserAlreadyExists
+			continue
+		}
+
+		for _, skill := range user.Skills {
+			userIds = append(userIds, user.ID)
+			slugs = append(slugs, skill.Slug)
+			levels = append(levels, int16(skill.Level))
+			years = append(years, int16(skill.Years))
+		}
+	}
+
+	_, err = tx.Exec(ctx, importUserSkills, userIds, slugs, levels, years)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return errs, nil
+}
+
+// insertUsers inserts the users at indexes in a savepoint, so a failed
+// insert doesn't abort tx, and returns the ids of the inserted ones.
+// Users with a taken email are skipped.
+func (s *Storage) insertUsers(
+	ctx context.Context,
+	tx pgx.Tx,
+	users []models.User,
+	indexes []int,
+	provider string,
+) ([]string, error) {
+	const op = "storage.postgres.insertUsers"
+
+	builder := s.psql.Insert("users").
+		Columns(
+			"id",
+			"email",
+			"name",
+			"password",
+			"about",
+			"avatar_key",
+			"avatar_thumbnails",
+			"provider",
+			"is_email_verified",
+		).
+		Suffix("ON CONFLICT DO NOTHING RETURNING id")
+	for _, i := range indexes {
+		user := users[i]
+		builder = builder.Values(
+			user.ID,
+			user.Email,
+			user.Name,
+			user.Password,
+			user.About,
+			user.AvatarKey,
+			user.AvatarThumbnails,
+			provider,
+			user.IsEmailVerified,
+		)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = savepoint.Rollback(ctx)
+	}()
+
+	rows, err := savepoint.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = savepoint.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
+}
+
+// activeSkills returns the set of slugs of the users which are in the catalog
+// and not deprecated.
+func (s *Storage) activeSkills(ctx context.Context, db Querier, users []models.User) (map[string]bool, error) {
+	const op = "storage.postgres.activeSkills"
+
+	var slugs []string
+	for _, user := range users {
+		for _, skill := range user.Skills {
+			slugs = append(slugs, skill.Slug)
+		}
+	}
+
+	query, args, err := s.psql.Select("slug").
+		From("skills").
+		Where("slug = ANY(?) AND NOT is_deprecated", slugs).
+		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	active, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	known := make(map[string]bool, len(active))
+	for _, slug := range active {
+		known[slug] = true
+	}
+
+	return known, nil
+}
@@ -5,8 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
-	"github.com/AlexMickh/proj-user/internal/consts"
 	"github.com/AlexMickh/proj-user/internal/models"
 	"github.com/AlexMickh/proj-user/internal/storage"
 	sq "github.com/Masterminds/squirrel"
@@ -14,12 +15,39 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
-type Postgres interface {
+type Querier interface {
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
+type Postgres interface {
+	Querier
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+var userColumns = []string{
+	"id",
+	"email",
+	"name",
+	"COALESCE(handle, '')",
+	"password",
+	"about",
+	userSkillsColumn,
+	"avatar_key",
+	"avatar_thumbnails",
+	"is_email_verified",
+	"suspended_at IS NOT NULL",
+}
+
+const userSkillsColumn = `COALESCE((
+	SELECT json_agg(json_build_object('slug', s.slug, 'level', us.level, 'years', us.years) ORDER BY s.slug)
+	FROM user_skills us JOIN skills s ON s.id = us.skill_id
+	WHERE us.user_id = users.id
+), '[]') AS skills`
+
+var returningUser = "RETURNING " + strings.Join(userColumns, ", ")
+
 type Storage struct {
 	db   Postgres
 	psql sq.StatementBuilderType
@@ -37,38 +65,83 @@ func (s *Storage) SaveUser(
 	id string,
 	email string,
 	name string,
+	handle string,
 	password string,
 	about string,
-	skills []string,
-	avatarUrl string,
+	skills []models.UserSkill,
+	avatarKey string,
+	avatarThumbnails models.AvatarThumbnails,
 	provider string,
+	isEmailVerified bool,
+	identity *models.Identity,
 ) error {
 	const op = "storage.postgres.SaveUser"
 
-	is_email_verified := false
-	if provider != consts.FieldProvider {
-		is_email_verified = true
-	}
-
 	query, args, err := s.psql.Insert("users").
-		Columns("id", "email", "name", "password", "about", "skills", "avatar_url", "provider", "is_email_verified").
-		Values(id, email, name, password, about, skills, avatarUrl, provider, is_email_verified).
+		Columns(
+			"id",
+			"email",
+			"name",
+			"handle",
+			"password",
+			"about",
+			"avatar_key",
+			"avatar_thumbnails",
+			"provider",
+			"is_email_verified",
+		).
+		Values(
+			id,
+			email,
+			name,
+			nullableHandle(handle),
+			password,
+			about,
+			avatarKey,
+			avatarThumbnails,
+			provider,
+			isEmailVerified,
+		).
 		ToSql()
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	_, err = s.db.Exec(ctx, query, args...)
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, query, args...)
 	if err != nil {
 		var pgErr *pgconn.PgError
-		if errors.As(err, &pgErr) {
-			if pgErr.Code == "22P02" {
-				return fmt.Errorf("%s: %w", op, storage.ErrInvalidSkills)
-			}
-			if pgErr.Code == "23505" {
-				return fmt.Errorf("%s: %w", op, storage.ErrUserAlreadyExists)
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			if pgErr.ConstraintName == handleConstraint {
+				return fmt.Errorf("%s: %w", op, storage.ErrHandleTaken)
 			}
+			return fmt.Errorf("%s: %w", op, storage.ErrUserAlreadyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = s.saveUserSkills(ctx, tx, id, skills, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if identity != nil {
+		identity.UserId = id
+		_, err = s.saveIdentity(ctx, tx, *identity)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
 		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
@@ -78,25 +151,15 @@ func (s *Storage) SaveUser(
 func (s *Storage) UserByEmail(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.postgres.UserByEmail"
 
-	query, args, err := s.psql.Select("id", "email", "name", "password", "about", "skills", "avatar_url", "is_email_verified").
+	query, args, err := s.psql.Select(userColumns...).
 		From("users").
-		Where("email = ?", email).
+		Where("email = ? AND deleted_at IS NULL", email).
 		ToSql()
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var user models.User
-	err = s.db.QueryRow(ctx, query, args...).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.Password,
-		&user.About,
-		&user.Skills,
-		&user.AvatarUrl,
-		&user.IsEmailVerified,
-	)
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -112,24 +175,15 @@ func (s *Storage) VerifyEmail(ctx context.Context, id string) (models.User, erro
 
 	query, args, err := s.psql.Update("users").
 		Set("is_email_verified", true).
-		Where("id = ?", id).
-		Suffix("RETURNING id, email, name, password, about, skills, avatar_url, is_email_verified").
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Suffix(returningUser).
 		ToSql()
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var user models.User
-	err = s.db.QueryRow(ctx, query, args...).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.Password,
-		&user.About,
-		&user.Skills,
-		&user.AvatarUrl,
-		&user.IsEmailVerified,
-	)
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -140,28 +194,99 @@ func (s *Storage) VerifyEmail(ctx context.Context, id string) (models.User, erro
 	return user, nil
 }
 
+// EmailExists tells whether any user, a deleted one included, has the email.
+func (s *Storage) EmailExists(ctx context.Context, email string) (bool, error) {
+	const op = "storage.postgres.EmailExists"
+
+	query, args, err := s.psql.Select("1").
+		From("users").
+		Where("email = ?", email).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exists bool
+	err = s.db.QueryRow(ctx, query, args...).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return exists, nil
+}
+
+// SetPendingEmail keeps the email the user wants to switch to
+// until the change is confirmed.
+func (s *Storage) SetPendingEmail(ctx context.Context, id string, email string) error {
+	const op = "storage.postgres.SetPendingEmail"
+
+	query, args, err := s.psql.Update("users").
+		Set("pending_email", email).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at IS NULL", id).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	tag, err := s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange replaces the email of the user with the pending one in
+// a single statement, the new email counts as verified.
+func (s *Storage) ConfirmEmailChange(ctx context.Context, id string) (models.User, error) {
+	const op = "storage.postgres.ConfirmEmailChange"
+
+	query, args, err := s.psql.Update("users").
+		Set("email", sq.Expr("pending_email")).
+		Set("pending_email", sq.Expr("NULL")).
+		Set("is_email_verified", true).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at IS NULL AND pending_email IS NOT NULL", id).
+		Suffix(returningUser).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		// the email was taken after the change was requested
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserAlreadyExists)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
 func (s *Storage) UserById(ctx context.Context, id string) (models.User, error) {
 	const op = "storage.postgres.UserById"
 
-	query, args, err := s.psql.Select("id", "email", "name", "password", "about", "skills", "avatar_url", "is_email_verified").
+	query, args, err := s.psql.Select(userColumns...).
 		From("users").
-		Where("id = ?", id).
+		Where("id = ? AND deleted_at IS NULL", id).
 		ToSql()
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var user models.User
-	err = s.db.QueryRow(ctx, query, args...).Scan(
-		&user.ID,
-		&user.Email,
-		&user.Name,
-		&user.Password,
-		&user.About,
-		&user.Skills,
-		&user.AvatarUrl,
-		&user.IsEmailVerified,
-	)
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -172,14 +297,13 @@ func (s *Storage) UserById(ctx context.Context, id string) (models.User, error)
 	return user, nil
 }
 
-func (s *Storage) UsersBySkills(ctx context.Context, userId string, skills []string) ([]models.User, error) {
-	const op = "storage.postgres.UsersBySkills"
+// UsersByIds returns the users found among ids in no particular order.
+func (s *Storage) UsersByIds(ctx context.Context, ids []string) ([]models.User, error) {
+	const op = "storage.postgres.UsersByIds"
 
-	query, args, err := s.psql.Select("id", "email", "name", "password", "about", "skills", "avatar_url", "is_email_verified").
+	query, args, err := s.psql.Select(userColumns...).
 		From("users").
-		Where("skills && ? AND is_email_verified = ? AND id != ?", skills, true, userId).
-		OrderBy("RANDOM()").
-		Limit(10).
+		Where("id = ANY(?) AND deleted_at IS NULL", ids).
 		ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -189,38 +313,302 @@ func (s *Storage) UsersBySkills(ctx context.Context, userId string, skills []str
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.User, error) {
+		return scanUser(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return users, nil
+}
+
+// UsersBySkills returns a page of verified users matching query. Users are
+// shuffled by cursor.Seed, optionally ranked by score first, and paged by
+// keyset. The returned cursor is nil on the last page.
+func (s *Storage) UsersBySkills(
+	ctx context.Context,
+	userId string,
+	search models.UsersQuery,
+	cursor models.UsersCursor,
+	limit int,
+) ([]models.UserMatch, *models.UsersCursor, error) {
+	const op = "storage.postgres.UsersBySkills"
+
+	score := skillsScore(search)
+	shuffle := sq.Expr("md5(id::text || ?)", cursor.Seed)
+
+	// the score is negated so the whole ordering stays ascending
+	// and a single row comparison is enough for the keyset
+	rank := sq.Expr("0::float8")
+	if search.ByRelevance {
+		rank = sq.Expr("-(?)", score)
+	}
+
+	builder := s.psql.Select(userColumns...).
+		Column(score).
+		Column(shuffle).
+		From("users").
+		Where(usersBySkillsFilter(userId, search))
+	if cursor.ID != "" {
+		builder = builder.Where(sq.Expr(
+			"(?, ?, id) > (?, ?, ?)",
+			rank,
+			shuffle,
+			-cursor.Score,
+			cursor.SortKey,
+			cursor.ID,
+		))
+	}
+
+	query, args, err := builder.
+		OrderByClause(sq.ConcatExpr(rank, ", ", shuffle, ", id")).
+		Limit(uint64(limit + 1)).
+		ToSql()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
 	defer rows.Close()
 
-	var users []models.User
+	var matches []models.UserMatch
+	var sortKeys []string
 	for rows.Next() {
-		var user models.User
-		rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Name,
-			&user.Password,
-			&user.About,
-			&user.Skills,
-			&user.AvatarUrl,
-			&user.IsEmailVerified,
-		)
-		users = append(users, user)
+		var match models.UserMatch
+		var sortKey string
+		match.User, err = scanUser(rows, &match.Score, &sortKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", op, err)
+		}
+		matches = append(matches, match)
+		sortKeys = append(sortKeys, sortKey)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(matches) <= limit {
+		return matches, nil, nil
 	}
-	if rows.Err() != nil {
+
+	matches = matches[:limit]
+	last := matches[limit-1]
+	next := &models.UsersCursor{
+		Seed:    cursor.Seed,
+		SortKey: sortKeys[limit-1],
+		ID:      last.User.ID,
+	}
+	if search.ByRelevance {
+		next.Score = last.Score
+	}
+
+	return matches, next, nil
+}
+
+func (s *Storage) CountUsersBySkills(ctx context.Context, userId string, search models.UsersQuery) (int64, error) {
+	const op = "storage.postgres.CountUsersBySkills"
+
+	query, args, err := s.psql.Select("COUNT(*)").
+		From("users").
+		Where(usersBySkillsFilter(userId, search)).
+		ToSql()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var count int64
+	err = s.db.QueryRow(ctx, query, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return count, nil
+}
+
+func (s *Storage) UpdateUser(ctx context.Context, id string, update models.UserUpdate) (models.User, error) {
+	const op = "storage.postgres.UpdateUser"
+
+	builder := s.psql.Update("users").
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP"))
+	if update.Name != nil {
+		builder = builder.Set("name", *update.Name)
+	}
+	if update.Handle != nil {
+		builder = builder.Set("handle", nullableHandle(*update.Handle))
+	}
+	if update.About != nil {
+		builder = builder.Set("about", *update.About)
+	}
+	if update.AvatarKey != nil {
+		builder = builder.Set("avatar_key", *update.AvatarKey)
+	}
+	if update.AvatarThumbnails != nil {
+		builder = builder.Set("avatar_thumbnails", update.AvatarThumbnails)
+	}
+
+	query, args, err := builder.
+		Where("id = ? AND deleted_at IS NULL", id).
+		Suffix(returningUser).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if update.Skills != nil {
+		rows, err := tx.Query(ctx, deleteUserSkills, id)
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+		deprecated, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		err = s.saveUserSkills(ctx, tx, id, *update.Skills, deprecated)
+		if err != nil {
+			return models.User{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	user, err := scanUser(tx.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrHandleTaken)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) UpdatePassword(ctx context.Context, id string, password string) (models.User, error) {
+	const op = "storage.postgres.UpdatePassword"
+
+	query, args, err := s.psql.Update("users").
+		Set("password", password).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Suffix(returningUser).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+func (s *Storage) SoftDeleteUser(ctx context.Context, id string) (models.User, error) {
+	const op = "storage.postgres.SoftDeleteUser"
+
+	query, args, err := s.psql.Update("users").
+		Set("deleted_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at IS NULL", id).
+		Suffix(returningUser).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// RestoreUser brings back a user that was soft deleted less than gracePeriod ago.
+func (s *Storage) RestoreUser(ctx context.Context, id string, gracePeriod time.Duration) (models.User, error) {
+	const op = "storage.postgres.RestoreUser"
+
+	query, args, err := s.psql.Update("users").
+		Set("deleted_at", nil).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("id = ? AND deleted_at > CURRENT_TIMESTAMP - make_interval(secs => ?)", id, gracePeriod.Seconds()).
+		Suffix(returningUser).
+		ToSql()
+	if err != nil {
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := scanUser(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user, nil
+}
+
+// PurgeDeletedUsers permanently removes users soft deleted at least gracePeriod ago
+// and returns their ids.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, gracePeriod time.Duration) ([]string, error) {
+	const op = "storage.postgres.PurgeDeletedUsers"
+
+	query, args, err := s.psql.Delete("users").
+		Where("deleted_at <= CURRENT_TIMESTAMP - make_interval(secs => ?)", gracePeriod.Seconds()).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return users, nil
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return ids, nil
 }
 
-func (s *Storage) RandomUsers(ctx context.Context, userId string) ([]models.User, error) {
-	const op = "storage.postgres.RandomUsers"
+// ExistingUserIds returns the ids that belong to users, soft deleted ones included.
+func (s *Storage) ExistingUserIds(ctx context.Context, ids []string) ([]string, error) {
+	const op = "storage.postgres.ExistingUserIds"
 
-	query, args, err := s.psql.Select("id", "email", "name", "password", "about", "skills", "avatar_url", "is_email_verified").
+	query, args, err := s.psql.Select("id").
 		From("users").
-		Where("is_email_verified = ? AND id != ?", true, userId).
-		OrderBy("RANDOM()").
-		Limit(10).
+		Where("id = ANY(?)", ids).
 		ToSql()
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
@@ -230,26 +618,182 @@ func (s *Storage) RandomUsers(ctx context.Context, userId string) ([]models.User
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	defer rows.Close()
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		rows.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Name,
-			&user.Password,
-			&user.About,
-			&user.Skills,
-			&user.AvatarUrl,
-			&user.IsEmailVerified,
+	existing, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return existing, nil
+}
+
+func (s *Storage) SaveToken(
+	ctx context.Context,
+	tokenHash string,
+	userId string,
+	kind string,
+	ttl time.Duration,
+) error {
+	const op = "storage.postgres.SaveToken"
+
+	query, args, err := s.psql.Insert("user_tokens").
+		Columns("token_hash", "user_id", "kind", "expires_at").
+		Values(tokenHash, userId, kind, sq.Expr("CURRENT_TIMESTAMP + make_interval(secs => ?)", ttl.Seconds())).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// UseToken marks a valid token as used and returns the id of its owner,
+// so every token can be used only once.
+func (s *Storage) UseToken(ctx context.Context, tokenHash string, kind string) (string, error) {
+	const op = "storage.postgres.UseToken"
+
+	query, args, err := s.psql.Update("user_tokens").
+		Set("used_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("token_hash = ? AND kind = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP", tokenHash, kind).
+		Suffix("RETURNING user_id").
+		ToSql()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	var userId string
+	err = s.db.QueryRow(ctx, query, args...).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", fmt.Errorf("%s: %w", op, storage.ErrTokenNotFound)
+		}
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return userId, nil
+}
+
+// RevokeTokens invalidates all unused tokens of the given kind issued to the user.
+func (s *Storage) RevokeTokens(ctx context.Context, userId string, kind string) error {
+	const op = "storage.postgres.RevokeTokens"
+
+	query, args, err := s.psql.Update("user_tokens").
+		Set("used_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where("user_id = ? AND kind = ? AND used_at IS NULL", userId, kind).
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// TokenIssuedWithin reports whether a token of the given kind was issued
+// to the user during the last interval.
+func (s *Storage) TokenIssuedWithin(
+	ctx context.Context,
+	userId string,
+	kind string,
+	interval time.Duration,
+) (bool, error) {
+	const op = "storage.postgres.TokenIssuedWithin"
+
+	query, args, err := s.psql.Select("1").
+		From("user_tokens").
+		Where("user_id = ? AND kind = ? AND created_at > CURRENT_TIMESTAMP - make_interval(secs => ?)", userId, kind, interval.Seconds()).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var exists bool
+	err = s.db.QueryRow(ctx, query, args...).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return exists, nil
+}
+
+func usersBySkillsFilter(userId string, search models.UsersQuery) sq.And {
+	filter := sq.And{
+		sq.Eq{"is_email_verified": true},
+		sq.NotEq{"id": userId},
+		sq.Expr("deleted_at IS NULL"),
+		sq.Expr("suspended_at IS NULL"),
+	}
+	if len(search.Skills) > 0 {
+		matched := sq.Expr(
+			"(SELECT COUNT(*) FROM user_skills us JOIN skills s ON s.id = us.skill_id "+
+				"WHERE us.user_id = users.id AND s.slug = ANY(?) AND us.level >= ?)",
+			search.Skills,
+			search.MinLevel,
 		)
-		users = append(users, user)
+		if search.MatchAll {
+			filter = append(filter, sq.Expr("? = ?", matched, len(search.Skills)))
+		} else {
+			filter = append(filter, sq.Expr("? > 0", matched))
+		}
 	}
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("%s: %w", op, err)
+
+	return filter
+}
+
+// skillsScore sums the weights of the requested skills the user has at MinLevel or above.
+func skillsScore(search models.UsersQuery) sq.Sqlizer {
+	weights := make([]float64, 0, len(search.Skills))
+	for _, skill := range search.Skills {
+		weight, ok := search.SkillWeights[skill]
+		if !ok {
+			weight = 1
+		}
+		weights = append(weights, weight)
 	}
 
-	return users, nil
+	return sq.Expr(
+		"(SELECT COALESCE(SUM(q.weight), 0)::float8 FROM unnest(?::text[], ?::float8[]) AS q(skill, weight) "+
+			"JOIN skills s ON s.slug = q.skill "+
+			"JOIN user_skills us ON us.skill_id = s.id AND us.user_id = users.id AND us.level >= ?)",
+		search.Skills,
+		weights,
+		search.MinLevel,
+	)
+}
+
+// scanUser scans the userColumns of a row, extra receives the columns
+// selected after them.
+func scanUser(row pgx.Row, extra ...any) (models.User, error) {
+	var user models.User
+	dest := []any{
+		&user.ID,
+		&user.Email,
+		&user.Name,
+		&user.Handle,
+		&user.Password,
+		&user.About,
+		&user.Skills,
+		&user.AvatarKey,
+		&user.AvatarThumbnails,
+		&user.IsEmailVerified,
+		&user.IsSuspended,
+	}
+	err := row.Scan(append(dest, extra...)...)
+
+	return user, err
 }
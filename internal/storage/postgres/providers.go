@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+)
+
+// SyncProviders upserts the configured providers, so users can reference them.
+// Providers missing from the configuration are kept for their existing users.
+func (s *Storage) SyncProviders(ctx context.Context, providers []models.Provider) error {
+	const op = "storage.postgres.SyncProviders"
+
+	if len(providers) == 0 {
+		return nil
+	}
+
+	builder := s.psql.Insert("providers").
+		Columns("name", "trust_email")
+	for _, provider := range providers {
+		builder = builder.Values(provider.Name, provider.TrustEmail)
+	}
+
+	query, args, err := builder.
+		Suffix("ON CONFLICT (name) DO UPDATE SET trust_email = EXCLUDED.trust_email, updated_at = CURRENT_TIMESTAMP").
+		ToSql()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = s.db.Exec(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
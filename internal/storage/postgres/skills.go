@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/internal/storage"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var skillColumns = []string{"id", "slug", "display_name", "category", "is_deprecated"}
+
+var returningSkill = "RETURNING " + strings.Join(skillColumns, ", ")
+
+const insertUserSkills = `INSERT INTO user_skills (user_id, skill_id, level, years)
+SELECT $1, s.id, q.level, q.years
+FROM unnest($2::text[], $3::int2[], $4::int2[]) AS q(slug, level, years)
+JOIN skills s ON s.slug = q.slug
+WHERE NOT s.is_deprecated OR s.slug = ANY($5::text[])`
+
+// deleteUserSkills unlinks all skills of a user
+// and returns the slugs of the deprecated ones.
+const deleteUserSkills = `WITH deleted AS (
+	DELETE FROM user_skills WHERE user_id = $1 RETURNING skill_id
+)
+SELECT s.slug FROM deleted d JOIN skills s ON s.id = d.skill_id
+WHERE s.is_deprecated`
+
+func (s *Storage) SaveSkill(ctx context.Context, slug string, displayName string, category string) (models.Skill, error) {
+	const op = "storage.postgres.SaveSkill"
+
+	query, args, err := s.psql.Insert("skills").
+		Columns("slug", "display_name", "category").
+		Values(slug, displayName, category).
+		Suffix(returningSkill).
+		ToSql()
+	if err != nil {
+		return models.Skill{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	skill, err := scanSkill(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return models.Skill{}, fmt.Errorf("%s: %w", op, storage.ErrSkillAlreadyExists)
+		}
+		return models.Skill{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skill, nil
+}
+
+func (s *Storage) UpdateSkill(ctx context.Context, slug string, update models.SkillUpdate) (models.Skill, error) {
+	const op = "storage.postgres.UpdateSkill"
+
+	builder := s.psql.Update("skills").
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP"))
+	if update.Slug != nil {
+		builder = builder.Set("slug", *update.Slug)
+	}
+	if update.DisplayName != nil {
+		builder = builder.Set("display_name", *update.DisplayName)
+	}
+	if update.Category != nil {
+		builder = builder.Set("category", *update.Category)
+	}
+	if update.IsDeprecated != nil {
+		builder = builder.Set("is_deprecated", *update.IsDeprecated)
+	}
+
+	query, args, err := builder.
+		Where("slug = ?", slug).
+		Suffix(returningSkill).
+		ToSql()
+	if err != nil {
+		return models.Skill{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	skill, err := scanSkill(s.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Skill{}, fmt.Errorf("%s: %w", op, storage.ErrSkillNotFound)
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return models.Skill{}, fmt.Errorf("%s: %w", op, storage.ErrSkillAlreadyExists)
+		}
+		return models.Skill{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skill, nil
+}
+
+func (s *Storage) Skills(ctx context.Context, category string, includeDeprecated bool) ([]models.Skill, error) {
+	const op = "storage.postgres.Skills"
+
+	builder := s.psql.Select(skillColumns...).
+		From("skills").
+		OrderBy("category", "slug")
+	if category != "" {
+		builder = builder.Where("category = ?", category)
+	}
+	if !includeDeprecated {
+		builder = builder.Where("NOT is_deprecated")
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var skills []models.Skill
+	for rows.Next() {
+		skill, err := scanSkill(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		skills = append(skills, skill)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return skills, nil
+}
+
+// saveUserSkills links the user to the skills with the given slugs.
+// Unknown skills fail with storage.ErrInvalidSkills, so do deprecated ones
+// unless listed in kept, the deprecated skills the user already had.
+func (s *Storage) saveUserSkills(
+	ctx context.Context,
+	db Querier,
+	userId string,
+	skills []models.UserSkill,
+	kept []string,
+) error {
+	const op = "storage.postgres.saveUserSkills"
+
+	if len(skills) == 0 {
+		return nil
+	}
+
+	slugs := make([]string, 0, len(skills))
+	levels := make([]int16, 0, len(skills))
+	years := make([]int16, 0, len(skills))
+	for _, skill := range skills {
+		slugs = append(slugs, skill.Slug)
+		levels = append(levels, int16(skill.Level))
+		years = append(years, int16(skill.Years))
+	}
+
+	tag, err := db.Exec(ctx, insertUserSkills, userId, slugs, levels, years, kept)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			if pgErr.Code == "23503" {
+				return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+			}
+			if pgErr.Code == "23505" || pgErr.Code == "23514" {
+				return fmt.Errorf("%s: %w", op, storage.ErrInvalidSkills)
+			}
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if tag.RowsAffected() != int64(len(skills)) {
+		return fmt.Errorf("%s: %w", op, storage.ErrInvalidSkills)
+	}
+
+	return nil
+}
+
+func scanSkill(row pgx.Row) (models.Skill, error) {
+	var skill models.Skill
+	err := row.Scan(
+		&skill.ID,
+		&skill.Slug,
+		&skill.DisplayName,
+		&skill.Category,
+		&skill.IsDeprecated,
+	)
+
+	return skill, err
+}
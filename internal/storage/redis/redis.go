@@ -2,7 +2,9 @@ package redis
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,22 +14,27 @@ import (
 
 type Cash interface {
 	HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	HGet(ctx context.Context, key string, field string) *redis.StringCmd
 	HGetAll(ctx context.Context, key string) *redis.MapStringStringCmd
+	Get(ctx context.Context, key string) *redis.StringCmd
 	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
-	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
-	RPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
-	LRange(ctx context.Context, key string, start int64, stop int64) *redis.StringSliceCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Pipelined(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
 }
 
 type Redis struct {
-	rdb        Cash
-	expiration time.Duration
+	rdb           Cash
+	expiration    time.Duration
+	urlExpiration time.Duration
 }
 
-func New(rdb Cash, expiration time.Duration) *Redis {
+func New(rdb Cash, expiration time.Duration, urlExpiration time.Duration) *Redis {
 	return &Redis{
-		rdb:        rdb,
-		expiration: expiration,
+		rdb:           rdb,
+		expiration:    expiration,
+		urlExpiration: urlExpiration,
 	}
 }
 
@@ -39,12 +46,7 @@ func (r *Redis) SaveUser(ctx context.Context, user models.User) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = r.rdb.RPush(ctx, genSkillsKey(user.ID), user.Skills).Err()
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
-	}
-
-	err = r.rdb.Expire(ctx, genSkillsKey(user.ID), r.expiration).Err()
+	err = r.saveSkills(ctx, user.ID, user.Skills)
 	if err != nil {
 		return fmt.Errorf("%s: %w", op, err)
 	}
@@ -55,37 +57,19 @@ func (r *Redis) SaveUser(ctx context.Context, user models.User) error {
 func (r *Redis) UserByEmail(ctx context.Context, email string) (models.User, error) {
 	const op = "storage.redis.UserByEmail"
 
-	var cursor uint64
-	var keys []string
-	var err error
-	keys, cursor, err = r.rdb.Scan(ctx, cursor, "*&"+email, 2).Result()
-	if err != nil || len(keys) == 0 {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
-
-	var user models.User
-	err = r.rdb.HGetAll(ctx, keys[0]).Scan(&user)
+	id, err := r.rdb.Get(ctx, genEmailKey(email)).Result()
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	arr := strings.Split(keys[0], "&")
-	user.ID = arr[0]
-	user.Email = arr[1]
-
-	user.Skills, err = r.rdb.LRange(ctx, genSkillsKey(user.ID), 0, -1).Result()
-	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
-	}
-
-	err = r.rdb.Expire(ctx, keys[0], r.expiration).Err()
+	user, err := r.UserById(ctx, id)
 	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = r.rdb.Expire(ctx, genSkillsKey(user.ID), r.expiration).Err()
-	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+	// the index may outlive an email change of the user
+	if !strings.EqualFold(user.Email, email) {
+		return models.User{}, fmt.Errorf("%s: %w", op, redis.Nil)
 	}
 
 	return user, nil
@@ -98,52 +82,167 @@ func (r *Redis) UpdateUser(ctx context.Context, user models.User) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	if err := r.saveSkills(ctx, user.ID, user.Skills); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
 func (r *Redis) UserById(ctx context.Context, id string) (models.User, error) {
-	const op = "storage.redis.UserByEmail"
+	const op = "storage.redis.UserById"
 
-	var cursor uint64
-	var keys []string
-	var err error
-	keys, cursor, err = r.rdb.Scan(ctx, cursor, id+"&*", 3).Result()
-	if err != nil || len(keys) == 0 {
+	users, err := r.UsersByIds(ctx, []string{id})
+	if err != nil {
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	var user models.User
-	err = r.rdb.HGetAll(ctx, keys[0]).Scan(&user)
+	user, ok := users[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("%s: %w", op, redis.Nil)
+	}
+
+	return user, nil
+}
+
+// UsersByIds looks all the users up in a single round trip and
+// returns the cached ones by id, the rest are cache misses.
+func (r *Redis) UsersByIds(ctx context.Context, ids []string) (map[string]models.User, error) {
+	const op = "storage.redis.UsersByIds"
+
+	userCmds := make([]*redis.MapStringStringCmd, len(ids))
+	skillsCmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			userCmds[i] = pipe.HGetAll(ctx, genKey(id))
+			skillsCmds[i] = pipe.HGetAll(ctx, genSkillsKey(id))
+			pipe.Expire(ctx, genKey(id), r.expiration)
+			pipe.Expire(ctx, genSkillsKey(id), r.expiration)
+		}
+		return nil
+	})
 	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	arr := strings.Split(keys[0], "&")
-	user.ID = arr[0]
-	user.Email = arr[1]
+	users := make(map[string]models.User, len(ids))
+	for i, id := range ids {
+		// a missing hash reads as an empty one
+		if len(userCmds[i].Val()) == 0 {
+			continue
+		}
+
+		var user models.User
+		if err := userCmds[i].Scan(&user); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		user.ID = id
+
+		user.Skills, err = parseSkills(skillsCmds[i].Val())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		users[id] = user
+	}
 
-	user.Skills, err = r.rdb.LRange(ctx, genSkillsKey(user.ID), 0, -1).Result()
+	return users, nil
+}
+
+func (r *Redis) DeleteUser(ctx context.Context, id string) error {
+	const op = "storage.redis.DeleteUser"
+
+	keys := []string{genKey(id), genSkillsKey(id)}
+
+	email, err := r.rdb.HGet(ctx, genKey(id), "email").Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if email != "" {
+		keys = append(keys, genEmailKey(email))
+	}
+
+	err = r.rdb.Del(ctx, keys...).Err()
 	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
 	}
 
-	err = r.rdb.Expire(ctx, keys[0], r.expiration).Err()
+	return nil
+}
+
+// DeleteEmail drops the index of an email that no longer belongs to the user.
+func (r *Redis) DeleteEmail(ctx context.Context, email string) error {
+	const op = "storage.redis.DeleteEmail"
+
+	err := r.rdb.Del(ctx, genEmailKey(email)).Err()
 	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// AvatarUrls returns the cached presigned urls of the object keys,
+// missing urls are left empty.
+func (r *Redis) AvatarUrls(ctx context.Context, keys []string) ([]string, error) {
+	const op = "storage.redis.AvatarUrls"
+
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, genAvatarUrlKey(key))
 	}
 
-	err = r.rdb.Expire(ctx, genSkillsKey(user.ID), r.expiration).Err()
+	values, err := r.rdb.MGet(ctx, cacheKeys...).Result()
 	if err != nil {
-		return models.User{}, fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return user, nil
+	urls := make([]string, len(values))
+	for i, value := range values {
+		if url, ok := value.(string); ok {
+			urls[i] = url
+		}
+	}
+
+	return urls, nil
+}
+
+// SaveAvatarUrls caches presigned urls by object key. They have to expire
+// earlier than the urls themselves, so clients never get a dead link.
+func (r *Redis) SaveAvatarUrls(ctx context.Context, urls map[string]string) error {
+	const op = "storage.redis.SaveAvatarUrls"
+
+	for key, url := range urls {
+		err := r.rdb.Set(ctx, genAvatarUrlKey(key), url, r.urlExpiration).Err()
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteAvatarUrls drops the cached urls of removed objects.
+func (r *Redis) DeleteAvatarUrls(ctx context.Context, keys []string) error {
+	const op = "storage.redis.DeleteAvatarUrls"
+
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, genAvatarUrlKey(key))
+	}
+
+	err := r.rdb.Del(ctx, cacheKeys...).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
 }
 
 func (r *Redis) saveUser(ctx context.Context, user models.User) error {
 	const op = "storage.redis.saveUser"
 
-	key := genKey(user.ID, user.Email)
+	key := genKey(user.ID)
 
 	err := r.rdb.HSet(ctx, key, user).Err()
 	if err != nil {
@@ -155,13 +254,79 @@ func (r *Redis) saveUser(ctx context.Context, user models.User) error {
 		return fmt.Errorf("%s: %w", op, err)
 	}
 
+	err = r.rdb.Set(ctx, genEmailKey(user.Email), user.ID, r.expiration).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
 	return nil
 }
 
-func genKey(id, email string) string {
-	return id + "&" + email
+// saveSkills replaces the cached skills, so stale entries never survive an update.
+// Skills are kept in a hash of slug to "level,years".
+func (r *Redis) saveSkills(ctx context.Context, id string, skills []models.UserSkill) error {
+	const op = "storage.redis.saveSkills"
+
+	key := genSkillsKey(id)
+
+	err := r.rdb.Del(ctx, key).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if len(skills) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(skills)*2)
+	for _, skill := range skills {
+		values = append(values, skill.Slug, fmt.Sprintf("%d,%d", skill.Level, skill.Years))
+	}
+
+	err = r.rdb.HSet(ctx, key, values).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = r.rdb.Expire(ctx, key, r.expiration).Err()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+func parseSkills(values map[string]string) ([]models.UserSkill, error) {
+	skills := make([]models.UserSkill, 0, len(values))
+	for slug, value := range values {
+		skill := models.UserSkill{Slug: slug}
+		_, err := fmt.Sscanf(value, "%d,%d", &skill.Level, &skill.Years)
+		if err != nil {
+			return nil, err
+		}
+		skills = append(skills, skill)
+	}
+
+	slices.SortFunc(skills, func(a, b models.UserSkill) int {
+		return strings.Compare(a.Slug, b.Slug)
+	})
+
+	return skills, nil
+}
+
+func genKey(id string) string {
+	return "user:" + id
 }
 
+// genEmailKey expects a normalized email, the lowercasing only keeps the
+// index case-insensitive for emails stored before normalization.
+func genEmailKey(email string) string {
+	return "user_email:" + strings.ToLower(email)
+}
 func genSkillsKey(id string) string {
-	return "skills:" + id
+	return "user_skills:" + id
+}
+
+func genAvatarUrlKey(key string) string {
+	return "avatar_url:" + key
 }
@@ -3,7 +3,15 @@ package storage
 import "errors"
 
 var (
-	ErrInvalidSkills     = errors.New("skill not in the skills list")
-	ErrUserAlreadyExists = errors.New("user already exists")
-	ErrUserNotFound      = errors.New("user not found")
+	ErrInvalidSkills      = errors.New("skill not in the skills list")
+	ErrUserAlreadyExists  = errors.New("user already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrTokenNotFound      = errors.New("token is invalid or expired")
+	ErrSkillNotFound      = errors.New("skill not found")
+	ErrSkillAlreadyExists = errors.New("skill already exists")
+	ErrIdentityNotFound   = errors.New("identity not found")
+	ErrIdentityLinked     = errors.New("identity is already linked to a user")
+	ErrLastLoginMethod    = errors.New("the last login method can't be removed")
+	ErrUserSuspended      = errors.New("user is suspended")
+	ErrHandleTaken        = errors.New("handle is already taken")
 )
@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/AlexMickh/proj-user/internal/consts"
+	"github.com/AlexMickh/proj-user/internal/models"
+	"github.com/AlexMickh/proj-user/pkg/utils/mailaddr"
+)
+
+// NewUser checks a user signing up with a password. The rules are shared by
+// the CreateUser rpc and the bulk import.
+func NewUser(email string, name string, password string, skills []models.UserSkill) error {
+	if err := ProviderUser(email, name, skills); err != nil {
+		return err
+	}
+
+	return Password(password)
+}
+
+// ProviderUser checks a user imported from an oauth provider, they sign in
+// through it and may have no password.
+func ProviderUser(email string, name string, skills []models.UserSkill) error {
+	if email == "" {
+		return errors.New("email is required")
+	}
+	if _, err := mailaddr.Normalize(email); err != nil {
+		return err
+	}
+	if err := Name(name); err != nil {
+		return err
+	}
+	if len(skills) == 0 {
+		return errors.New("skills is required")
+	}
+
+	return Skills(skills)
+}
+
+// Name checks a display name against the size of its column.
+func Name(name string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if utf8.RuneCountInString(name) > consts.MaxNameLength {
+		return fmt.Errorf("name is longer than %d characters", consts.MaxNameLength)
+	}
+
+	return nil
+}
+
+// Password checks a password before it is hashed.
+func Password(password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if len(password) > consts.MaxPasswordLength {
+		return fmt.Errorf("password is longer than %d bytes", consts.MaxPasswordLength)
+	}
+
+	return nil
+}
+
+// Skills checks the slugs, levels and years of the skills of a user.
+func Skills(skills []models.UserSkill) error {
+	seen := make(map[string]bool, len(skills))
+	for _, skill := range skills {
+		if skill.Slug == "" {
+			return errors.New("skill slug is required")
+		}
+		if seen[skill.Slug] {
+			return fmt.Errorf("skill %s is duplicated", skill.Slug)
+		}
+		if skill.Level < consts.SkillLevelUnspecified || skill.Level > consts.SkillLevelLead {
+			return fmt.Errorf("invalid level of skill %s", skill.Slug)
+		}
+		if skill.Years < 0 || skill.Years > consts.MaxSkillYears {
+			return fmt.Errorf("invalid years of skill %s", skill.Slug)
+		}
+		seen[skill.Slug] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+CREATE TYPE provider AS ENUM(
+    'fields',
+    'yandex'
+);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_provider_fkey;
+
+-- the enum knows only the original providers, the rest can't be kept
+UPDATE users SET provider = NULL WHERE provider NOT IN ('fields', 'yandex');
+ALTER TABLE users ALTER COLUMN provider TYPE provider USING provider::provider;
+
+DROP TABLE IF EXISTS providers;
\ No newline at end of file
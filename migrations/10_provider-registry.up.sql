@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS providers(
+    name TEXT PRIMARY KEY,
+    trust_email BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+INSERT INTO providers(name, trust_email) VALUES ('fields', false), ('yandex', true);
+
+ALTER TABLE users ALTER COLUMN provider TYPE TEXT USING provider::TEXT;
+ALTER TABLE users ADD CONSTRAINT users_provider_fkey FOREIGN KEY (provider) REFERENCES providers(name);
+
+DROP TYPE IF EXISTS provider;
\ No newline at end of file
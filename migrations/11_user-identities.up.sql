@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS user_identities(
+    provider TEXT NOT NULL REFERENCES providers(name),
+    provider_subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, provider_subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities(user_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_length_check;
+
+-- fails when longer emails were stored meanwhile, they can't be cut silently
+ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(50);
\ No newline at end of file
@@ -0,0 +1,28 @@
+CREATE EXTENSION IF NOT EXISTS citext;
+
+-- accounts differing only in the case of the email have to be merged by hand,
+-- the migration stops and lists them instead of picking one
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(emails, '; ') INTO collisions
+    FROM (
+        SELECT string_agg(email, ', ' ORDER BY created_at) AS emails
+        FROM users
+        WHERE email IS NOT NULL
+        GROUP BY lower(trim(email))
+        HAVING count(*) > 1
+    ) c;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'users with colliding emails: %', collisions;
+    END IF;
+END $$;
+
+-- sql has no punycode support, so existing emails with an internationalized
+-- domain keep the unicode form and have to be fixed by hand
+UPDATE users SET email = lower(trim(email)) WHERE email <> lower(trim(email));
+
+ALTER TABLE users ALTER COLUMN email TYPE CITEXT;
+ALTER TABLE users ADD CONSTRAINT users_email_length_check CHECK (length(email) <= 254);
\ No newline at end of file
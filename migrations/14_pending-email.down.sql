@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN pending_email;
\ No newline at end of file
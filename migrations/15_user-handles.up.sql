@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN handle CITEXT;
+
+ALTER TABLE users ADD CONSTRAINT users_handle_key UNIQUE (handle);
+
+ALTER TABLE users ADD CONSTRAINT users_handle_check
+    CHECK (length(handle) BETWEEN 3 AND 30 AND handle::text ~ '^[a-z0-9]+([_-][a-z0-9]+)*$');
\ No newline at end of file
@@ -1 +1,3 @@
-DROP TABLE IF EXISTS users;
\ No newline at end of file
+DROP TABLE IF EXISTS users;
+
+DROP TYPE IF EXISTS skill;
\ No newline at end of file
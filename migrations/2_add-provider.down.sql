@@ -1 +1,3 @@
-ALTER TABLE users DROP COLUMN provider;
\ No newline at end of file
+ALTER TABLE users DROP COLUMN provider;
+
+DROP TYPE IF EXISTS provider;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- hashed passwords can not be restored to plain text
+SELECT 1;
\ No newline at end of file
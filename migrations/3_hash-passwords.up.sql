@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+-- only real bcrypt and argon2id hashes are skipped,
+-- a plaintext password may start with $ as well
+UPDATE users
+SET password = crypt(password, gen_salt('bf', 12))
+WHERE password IS NOT NULL AND password <> ''
+    AND password !~ '^\$(2[aby]\$[0-9]{2}\$|argon2id\$)';
\ No newline at end of file
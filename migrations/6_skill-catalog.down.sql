@@ -0,0 +1,22 @@
+CREATE TYPE skill AS ENUM(
+    'backend',
+    'frontend',
+    'go',
+    'docker'
+);
+
+ALTER TABLE users ADD COLUMN skills skill[];
+
+-- skills added to the catalog later have no enum value and are lost
+UPDATE users u
+SET skills = ARRAY(
+    SELECT s.slug::skill
+    FROM user_skills us
+    JOIN skills s ON s.id = us.skill_id
+    WHERE us.user_id = u.id AND s.slug IN ('backend', 'frontend', 'go', 'docker')
+    ORDER BY s.slug
+);
+
+DROP TABLE IF EXISTS user_skills;
+
+DROP TABLE IF EXISTS skills;
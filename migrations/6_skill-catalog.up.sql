@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS skills(
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(50) UNIQUE NOT NULL,
+    display_name VARCHAR(100) NOT NULL,
+    category VARCHAR(50) NOT NULL DEFAULT 'other',
+    is_deprecated BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+INSERT INTO skills(slug, display_name, category) VALUES
+    ('backend', 'Backend', 'role'),
+    ('frontend', 'Frontend', 'role'),
+    ('go', 'Go', 'language'),
+    ('docker', 'Docker', 'tool')
+ON CONFLICT (slug) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_skills(
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    skill_id INTEGER NOT NULL REFERENCES skills(id),
+    PRIMARY KEY (user_id, skill_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_skills_skill_id_idx ON user_skills(skill_id);
+
+INSERT INTO user_skills(user_id, skill_id)
+SELECT u.id, s.id
+FROM users u
+CROSS JOIN LATERAL unnest(u.skills) AS us(skill)
+JOIN skills s ON s.slug = us.skill::text
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN skills;
+
+DROP TYPE IF EXISTS skill;
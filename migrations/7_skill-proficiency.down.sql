@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS user_skills_skill_id_level_idx;
+
+CREATE INDEX IF NOT EXISTS user_skills_skill_id_idx ON user_skills(skill_id);
+
+ALTER TABLE user_skills
+    DROP COLUMN level,
+    DROP COLUMN years;
@@ -0,0 +1,7 @@
+ALTER TABLE user_skills
+    ADD COLUMN level SMALLINT NOT NULL DEFAULT 0 CHECK (level BETWEEN 0 AND 4),
+    ADD COLUMN years SMALLINT NOT NULL DEFAULT 0 CHECK (years BETWEEN 0 AND 70);
+
+DROP INDEX IF EXISTS user_skills_skill_id_idx;
+
+CREATE INDEX IF NOT EXISTS user_skills_skill_id_level_idx ON user_skills(skill_id, level);
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN avatar_thumbnails JSONB NOT NULL DEFAULT '{}';
\ No newline at end of file
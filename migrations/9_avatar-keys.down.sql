@@ -0,0 +1,2 @@
+-- presigned urls expire, so the keys are kept and only the column is renamed back
+ALTER TABLE users RENAME COLUMN avatar_key TO avatar_url;
\ No newline at end of file
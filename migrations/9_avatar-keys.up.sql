@@ -0,0 +1,14 @@
+ALTER TABLE users RENAME COLUMN avatar_url TO avatar_key;
+
+-- presigned urls end with the object key followed by the signature query,
+-- both path and virtual host style urls are handled
+UPDATE users
+SET avatar_key = regexp_replace(avatar_key, '^.*/([^/?]+)(\?.*)?$', '\1')
+WHERE avatar_key LIKE 'http%';
+
+UPDATE users
+SET avatar_thumbnails = (
+    SELECT COALESCE(jsonb_object_agg(t.key, regexp_replace(t.value, '^.*/([^/?]+)(\?.*)?$', '\1')), '{}')
+    FROM jsonb_each_text(users.avatar_thumbnails) AS t
+)
+WHERE avatar_thumbnails <> '{}';
\ No newline at end of file
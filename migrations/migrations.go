@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+	"os"
+)
+
+//go:embed *.sql
+var embedded embed.FS
+
+// Source returns the migrations in the directory at path, or the ones
+// embedded into the binary when path is empty.
+func Source(path string) fs.FS {
+	if path == "" {
+		return embedded
+	}
+
+	return os.DirFS(path)
+}
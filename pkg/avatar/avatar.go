@@ -0,0 +1,503 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	PNG  = "image/png"
+	JPEG = "image/jpeg"
+	GIF  = "image/gif"
+	WebP = "image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrInvalidImage      = errors.New("invalid image")
+	ErrTooLarge          = errors.New("image dimensions are too large")
+)
+
+// formats maps the sniffed content type to the name of the image decoder.
+var formats = map[string]string{
+	PNG:  "png",
+	JPEG: "jpeg",
+	GIF:  "gif",
+	WebP: "webp",
+}
+
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+	// Orientation is the exif orientation of a JPEG image, 1 when upright.
+	// The pixels are stored as shot, viewers rotate them.
+	Orientation int
+}
+
+const thumbnailQuality = 85
+
+type Processor struct {
+	maxWidth       int
+	maxHeight      int
+	thumbnailSizes []int
+}
+
+func New(maxWidth int, maxHeight int, thumbnailSizes []int) *Processor {
+	return &Processor{
+		maxWidth:       maxWidth,
+		maxHeight:      maxHeight,
+		thumbnailSizes: thumbnailSizes,
+	}
+}
+
+// ThumbnailSizes returns the configured thumbnail sizes in pixels.
+func (p *Processor) ThumbnailSizes() []int {
+	return p.thumbnailSizes
+}
+
+// Process detects the real format of data, makes sure it is a whole image
+// within the allowed dimensions and strips its metadata.
+func (p *Processor) Process(data []byte) (Image, error) {
+	const op = "avatar.Process"
+
+	contentType := http.DetectContentType(data)
+	format, ok := formats[contentType]
+	if !ok {
+		return Image{}, fmt.Errorf("%s: %w: %s", op, ErrUnsupportedFormat, contentType)
+	}
+
+	// dimensions are checked before decoding, so huge images are never allocated
+	cfg, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return Image{}, fmt.Errorf("%s: %w", op, ErrInvalidImage)
+	}
+	if cfg.Width > p.maxWidth || cfg.Height > p.maxHeight {
+		return Image{}, fmt.Errorf("%s: %w: %dx%d", op, ErrTooLarge, cfg.Width, cfg.Height)
+	}
+
+	if _, _, err := image.Decode(bytes.NewReader(data)); err != nil {
+		return Image{}, fmt.Errorf("%s: %w", op, ErrInvalidImage)
+	}
+
+	var stripped []byte
+	orientation := 1
+	switch contentType {
+	case PNG:
+		stripped, err = stripPNG(data)
+	case JPEG:
+		stripped, orientation, err = stripJPEG(data)
+	case GIF:
+		stripped, err = stripGIF(data)
+	case WebP:
+		stripped, err = stripWebP(data)
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		width, height = height, width
+	}
+
+	return Image{
+		Data:        stripped,
+		ContentType: contentType,
+		Width:       width,
+		Height:      height,
+		Orientation: orientation,
+	}, nil
+}
+
+// Thumbnails crops the center square of img and scales it down to every
+// configured size, smaller images are never scaled up. JPEG images produce
+// JPEG thumbnails, the rest PNG ones to keep the transparency. Thumbnails
+// carry no metadata, so the orientation of img is applied to their pixels.
+func (p *Processor) Thumbnails(img Image) (map[int]Image, error) {
+	const op = "avatar.Thumbnails"
+
+	src, _, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidImage)
+	}
+
+	bounds := src.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Point{
+		X: bounds.Min.X + (bounds.Dx()-side)/2,
+		Y: bounds.Min.Y + (bounds.Dy()-side)/2,
+	})
+
+	thumbnails := make(map[int]Image, len(p.thumbnailSizes))
+	for _, size := range p.thumbnailSizes {
+		dstSide := min(size, side)
+		dst := image.NewRGBA(image.Rect(0, 0, dstSide, dstSide))
+		draw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, draw.Src, nil)
+		// the center square of a rotated image is the rotated center square
+		dst = orient(dst, img.Orientation)
+
+		thumbnail := Image{
+			ContentType: PNG,
+			Width:       dstSide,
+			Height:      dstSide,
+		}
+
+		var buf bytes.Buffer
+		if img.ContentType == JPEG {
+			thumbnail.ContentType = JPEG
+			err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality})
+		} else {
+			err = png.Encode(&buf, dst)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		thumbnail.Data = buf.Bytes()
+
+		thumbnails[size] = thumbnail
+	}
+
+	return thumbnails, nil
+}
+
+// stripPNG drops the exif and textual chunks.
+func stripPNG(data []byte) ([]byte, error) {
+	const signatureLen = 8
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:signatureLen])
+
+	for rest := data[signatureLen:]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, ErrInvalidImage
+		}
+		size := int(binary.BigEndian.Uint32(rest[:4]))
+		if size > len(rest)-12 {
+			return nil, ErrInvalidImage
+		}
+		chunkLen := size + 12
+
+		switch string(rest[4:8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(rest[:chunkLen])
+		}
+
+		rest = rest[chunkLen:]
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripJPEG drops the APP1 (exif, xmp), APP13 (iptc) and comment segments
+// and returns the exif orientation. The orientation is written back in a
+// minimal exif segment, the ICC profile and the Adobe segment are kept as
+// they affect colors.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+
+	orientation := 1
+
+	for i := 2; ; {
+		if i+1 >= len(data) || data[i] != 0xff {
+			return nil, 0, ErrInvalidImage
+		}
+		marker := data[i+1]
+
+		switch {
+		case marker == 0xff:
+			// fill byte before a marker
+			i++
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// standalone markers have no length
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		case marker == 0xda || marker == 0xd9:
+			// start of scan, the rest is entropy-coded data
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		}
+
+		if i+4 > len(data) {
+			return nil, 0, ErrInvalidImage
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:i+4]))
+		if end > len(data) || end < i+4 {
+			return nil, 0, ErrInvalidImage
+		}
+
+		switch marker {
+		case 0xe1:
+			if orientation == 1 {
+				orientation = exifOrientation(data[i+4 : end])
+				if orientation != 1 {
+					out.Write(orientationSegment(orientation))
+				}
+			}
+		case 0xed, 0xfe:
+		default:
+			out.Write(data[i:end])
+		}
+
+		i = end
+	}
+}
+
+// exifOrientation returns the orientation tag of the first IFD of an exif
+// APP1 payload, 1 when there is none.
+func exifOrientation(payload []byte) int {
+	const orientationTag = 0x0112
+
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[offset:]))
+	for i := range count {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		// a single SHORT stored in the value field
+		if order.Uint16(tiff[entry:]) != orientationTag || order.Uint16(tiff[entry+2:]) != 3 {
+			continue
+		}
+
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+
+	return 1
+}
+
+// orientationSegment returns an APP1 segment holding nothing but the
+// orientation tag.
+func orientationSegment(orientation int) []byte {
+	return []byte{
+		0xff, 0xe1, 0x00, 0x22, // marker and length
+		'E', 'x', 'i', 'f', 0x00, 0x00,
+		'I', 'I', 0x2a, 0x00, 0x08, 0x00, 0x00, 0x00, // little endian tiff header
+		0x01, 0x00, // one entry
+		0x12, 0x01, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, byte(orientation), 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+}
+
+// orient turns the pixels of img stored with the exif orientation upright.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := range dstH {
+		for x := range dstW {
+			var sx, sy int
+			switch orientation {
+			case 2:
+				sx, sy = w-1-x, y
+			case 3:
+				sx, sy = w-1-x, h-1-y
+			case 4:
+				sx, sy = x, h-1-y
+			case 5:
+				sx, sy = y, x
+			case 6:
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8:
+				sx, sy = w-1-y, x
+			}
+			dst.SetRGBA(x, y, img.RGBAAt(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
+
+// stripGIF drops the comment extensions and the application extensions other
+// than the loop count. Blocks are copied as is, so no frame is ever decoded.
+func stripGIF(data []byte) ([]byte, error) {
+	const (
+		headerLen        = 13
+		colorTableFlag   = 0x80
+		extension        = 0x21
+		imageDescriptor  = 0x2c
+		trailer          = 0x3b
+		commentLabel     = 0xfe
+		applicationLabel = 0xff
+	)
+
+	if len(data) < headerLen {
+		return nil, ErrInvalidImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+
+	i := headerLen + colorTableLen(data[10], colorTableFlag)
+	if i > len(data) {
+		return nil, ErrInvalidImage
+	}
+	out.Write(data[:i])
+
+	for {
+		if i >= len(data) {
+			return nil, ErrInvalidImage
+		}
+
+		switch data[i] {
+		case trailer:
+			out.WriteByte(trailer)
+			return out.Bytes(), nil
+		case extension:
+			if i+2 > len(data) {
+				return nil, ErrInvalidImage
+			}
+			label := data[i+1]
+			end, err := skipSubBlocks(data, i+2)
+			if err != nil {
+				return nil, err
+			}
+
+			keep := label != commentLabel
+			if label == applicationLabel {
+				// the application identifier is the first sub-block
+				app := string(data[i+3 : min(i+14, end)])
+				keep = app == "NETSCAPE2.0" || app == "ANIMEXTS1.0"
+			}
+			if keep {
+				out.Write(data[i:end])
+			}
+
+			i = end
+		case imageDescriptor:
+			if i+10 > len(data) {
+				return nil, ErrInvalidImage
+			}
+			// descriptor, local color table and the lzw minimum code size
+			start := i + 10 + colorTableLen(data[i+9], colorTableFlag) + 1
+			if start > len(data) {
+				return nil, ErrInvalidImage
+			}
+			end, err := skipSubBlocks(data, start)
+			if err != nil {
+				return nil, err
+			}
+
+			out.Write(data[i:end])
+			i = end
+		default:
+			return nil, ErrInvalidImage
+		}
+	}
+}
+
+// colorTableLen returns the length of the color table described by the
+// packed fields of a gif screen or image descriptor.
+func colorTableLen(fields byte, flag byte) int {
+	if fields&flag == 0 {
+		return 0
+	}
+	return 3 << (fields&0x07 + 1)
+}
+
+// skipSubBlocks returns the index after the gif data sub-blocks starting at i.
+func skipSubBlocks(data []byte, i int) (int, error) {
+	for {
+		if i >= len(data) {
+			return 0, ErrInvalidImage
+		}
+		size := int(data[i])
+		i++
+		if size == 0 {
+			return i, nil
+		}
+		i += size
+	}
+}
+
+// stripWebP drops the EXIF and XMP chunks and clears their flags in the VP8X header.
+func stripWebP(data []byte) ([]byte, error) {
+	const (
+		headerLen = 12
+		exifFlag  = 0x08
+		xmpFlag   = 0x04
+	)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:headerLen])
+
+	for rest := data[headerLen:]; len(rest) > 0; {
+		if len(rest) < 8 {
+			return nil, ErrInvalidImage
+		}
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		if size > len(rest)-8 {
+			return nil, ErrInvalidImage
+		}
+		// some encoders omit the padding byte of the last chunk
+		chunkLen := min(8+size+size%2, len(rest))
+
+		switch string(rest[:4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(rest[:chunkLen])
+			if len(chunk) > 8 {
+				chunk[8] &^= exifFlag | xmpFlag
+			}
+			out.Write(chunk)
+		default:
+			out.Write(rest[:chunkLen])
+		}
+
+		rest = rest[chunkLen:]
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:8], uint32(len(stripped)-8))
+
+	return stripped, nil
+}
@@ -0,0 +1,373 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+const secret = "GPS 55.7558 37.6173"
+
+// webpLossless is a 1x1 lossless WebP image.
+const webpLossless = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatalf("jpeg.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func encodeGIF(t *testing.T, frames int) []byte {
+	t.Helper()
+
+	animation := &gif.GIF{}
+	for range frames {
+		animation.Image = append(animation.Image, image.NewPaletted(image.Rect(0, 0, 8, 8), palette.Plan9))
+		animation.Delay = append(animation.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		t.Fatalf("gif.EncodeAll() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+// pngChunk builds a chunk with a valid crc.
+func pngChunk(kind string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, kind...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// jpegSegment builds a segment with the given marker.
+func jpegSegment(marker byte, data []byte) []byte {
+	segment := []byte{0xff, marker}
+	segment = binary.BigEndian.AppendUint16(segment, uint16(len(data)+2))
+	return append(segment, data...)
+}
+
+// exifSegment builds a big endian exif APP1 segment holding the orientation
+// and a string tag.
+func exifSegment(orientation int) []byte {
+	tiff := []byte{'M', 'M', 0x00, 0x2a, 0x00, 0x00, 0x00, 0x08}
+	tiff = binary.BigEndian.AppendUint16(tiff, 2)
+	// orientation, SHORT, one value
+	tiff = append(tiff, 0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = append(tiff, 0x00, 0x00)
+	// image description, ASCII, stored after the IFD
+	tiff = append(tiff, 0x01, 0x0e, 0x00, 0x02)
+	tiff = binary.BigEndian.AppendUint32(tiff, uint32(len(secret)))
+	tiff = binary.BigEndian.AppendUint32(tiff, 8+2+2*12+4)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00)
+	tiff = append(tiff, secret...)
+
+	return jpegSegment(0xe1, append([]byte("Exif\x00\x00"), tiff...))
+}
+
+// insert puts extra into data at offset.
+func insert(data []byte, offset int, extra ...[]byte) []byte {
+	out := bytes.Clone(data[:offset])
+	for _, e := range extra {
+		out = append(out, e...)
+	}
+	return append(out, data[offset:]...)
+}
+
+// webpWithExif wraps the lossless image in an extended file with exif and xmp chunks.
+func webpWithExif(t *testing.T) []byte {
+	t.Helper()
+
+	simple, err := base64.StdEncoding.DecodeString(webpLossless)
+	if err != nil {
+		t.Fatalf("DecodeString() error = %v", err)
+	}
+
+	chunk := func(kind string, data []byte) []byte {
+		c := append([]byte(kind), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+		c = append(c, data...)
+		if len(data)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+
+	// exif and xmp flags set, 1x1 canvas
+	vp8x := []byte{0x0c, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", vp8x)...)
+	body = append(body, simple[12:]...)
+	body = append(body, chunk("EXIF", []byte(secret))...)
+	body = append(body, chunk("XMP ", []byte(secret))...)
+
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestProcessRejects(t *testing.T) {
+	p := New(64, 64, nil)
+
+	png := encodePNG(t, 16, 16)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "text", data: []byte("definitely not an image"), wantErr: ErrUnsupportedFormat},
+		{name: "svg", data: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), wantErr: ErrUnsupportedFormat},
+		{name: "truncated png", data: png[:len(png)/2], wantErr: ErrInvalidImage},
+		{name: "png signature only", data: png[:8], wantErr: ErrInvalidImage},
+		{name: "too wide", data: encodePNG(t, 65, 16), wantErr: ErrTooLarge},
+		{name: "too high", data: encodeJPEG(t, 16, 65), wantErr: ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := p.Process(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProcessStripsMetadata(t *testing.T) {
+	p := New(64, 64, nil)
+
+	pngData := encodePNG(t, 16, 16)
+	jpegData := encodeJPEG(t, 16, 16)
+	gifData := encodeGIF(t, 3)
+
+	comment := append([]byte{0x21, 0xfe, byte(len(secret))}, secret...)
+	comment = append(comment, 0)
+
+	tests := []struct {
+		name            string
+		data            []byte
+		wantContentType string
+	}{
+		{
+			name: "png",
+			// right after the IHDR chunk
+			data: insert(pngData, 33,
+				pngChunk("tEXt", []byte("Comment\x00"+secret)),
+				pngChunk("eXIf", []byte(secret)),
+			),
+			wantContentType: PNG,
+		},
+		{
+			name: "jpeg",
+			data: insert(jpegData, 2,
+				exifSegment(1),
+				jpegSegment(0xe1, []byte("http://ns.adobe.com/xap/1.0/\x00"+secret)),
+				jpegSegment(0xfe, []byte(secret)),
+			),
+			wantContentType: JPEG,
+		},
+		{
+			name:            "gif",
+			data:            insert(gifData, len(gifData)-1, comment),
+			wantContentType: GIF,
+		},
+		{
+			name:            "webp",
+			data:            webpWithExif(t),
+			wantContentType: WebP,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !bytes.Contains(tt.data, []byte(secret)) {
+				t.Fatal("test image holds no metadata")
+			}
+
+			img, err := p.Process(tt.data)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+			if img.ContentType != tt.wantContentType {
+				t.Errorf("ContentType = %s, want %s", img.ContentType, tt.wantContentType)
+			}
+			if bytes.Contains(img.Data, []byte(secret)) {
+				t.Error("metadata is not stripped")
+			}
+			if _, _, err := image.Decode(bytes.NewReader(img.Data)); err != nil {
+				t.Errorf("stripped image doesn't decode: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripGIFKeepsFrames(t *testing.T) {
+	data := encodeGIF(t, 3)
+
+	stripped, err := stripGIF(data)
+	if err != nil {
+		t.Fatalf("stripGIF() error = %v", err)
+	}
+
+	animation, err := gif.DecodeAll(bytes.NewReader(stripped))
+	if err != nil {
+		t.Fatalf("DecodeAll() error = %v", err)
+	}
+	if len(animation.Image) != 3 {
+		t.Errorf("frames = %d, want 3", len(animation.Image))
+	}
+
+	if _, err := stripGIF(data[:len(data)-4]); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("stripGIF() of a truncated gif error = %v, want %v", err, ErrInvalidImage)
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	p := New(64, 64, []int{8})
+
+	for orientation := 1; orientation <= 8; orientation++ {
+		data := insert(encodeJPEG(t, 16, 8), 2, exifSegment(orientation))
+
+		img, err := p.Process(data)
+		if err != nil {
+			t.Fatalf("Process() error = %v", err)
+		}
+		if img.Orientation != orientation {
+			t.Errorf("Orientation = %d, want %d", img.Orientation, orientation)
+		}
+
+		wantWidth, wantHeight := 16, 8
+		if orientation >= 5 {
+			wantWidth, wantHeight = 8, 16
+		}
+		if img.Width != wantWidth || img.Height != wantHeight {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", orientation, img.Width, img.Height, wantWidth, wantHeight)
+		}
+
+		segment := orientationSegment(orientation)
+		if got := bytes.Contains(img.Data, segment); got != (orientation != 1) {
+			t.Errorf("orientation %d: orientation segment kept = %t", orientation, got)
+		}
+		if orientation != 1 && exifOrientation(segment[4:]) != orientation {
+			t.Errorf("orientation %d: segment reads back as %d", orientation, exifOrientation(segment[4:]))
+		}
+	}
+}
+
+func TestExifOrientationMalformed(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload []byte
+	}{
+		{name: "empty", payload: nil},
+		{name: "xmp", payload: []byte("http://ns.adobe.com/xap/1.0/\x00")},
+		{name: "short tiff header", payload: []byte("Exif\x00\x00MM\x00")},
+		{name: "bad byte order", payload: []byte("Exif\x00\x00XX\x00\x2a\x00\x00\x00\x08")},
+		{name: "ifd out of range", payload: []byte("Exif\x00\x00II\x2a\x00\xff\x00\x00\x00")},
+		{name: "truncated entries", payload: []byte("Exif\x00\x00II\x2a\x00\x08\x00\x00\x00\x05\x00\x12\x01")},
+		{name: "orientation out of range", payload: exifSegment(9)[4:]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exifOrientation(tt.payload); got != 1 {
+				t.Errorf("exifOrientation() = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 2x1 image, the left pixel is marked
+	src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	marked := color.RGBA{R: 255, A: 255}
+	src.SetRGBA(0, 0, marked)
+
+	tests := []struct {
+		orientation int
+		wantSize    image.Point
+		wantMarked  image.Point
+	}{
+		{orientation: 1, wantSize: image.Pt(2, 1), wantMarked: image.Pt(0, 0)},
+		{orientation: 2, wantSize: image.Pt(2, 1), wantMarked: image.Pt(1, 0)},
+		{orientation: 3, wantSize: image.Pt(2, 1), wantMarked: image.Pt(1, 0)},
+		{orientation: 4, wantSize: image.Pt(2, 1), wantMarked: image.Pt(0, 0)},
+		{orientation: 5, wantSize: image.Pt(1, 2), wantMarked: image.Pt(0, 0)},
+		{orientation: 6, wantSize: image.Pt(1, 2), wantMarked: image.Pt(0, 0)},
+		{orientation: 7, wantSize: image.Pt(1, 2), wantMarked: image.Pt(0, 1)},
+		{orientation: 8, wantSize: image.Pt(1, 2), wantMarked: image.Pt(0, 1)},
+	}
+
+	for _, tt := range tests {
+		dst := orient(src, tt.orientation)
+		if size := dst.Bounds().Size(); size != tt.wantSize {
+			t.Errorf("orientation %d: size = %v, want %v", tt.orientation, size, tt.wantSize)
+			continue
+		}
+		if got := dst.RGBAAt(tt.wantMarked.X, tt.wantMarked.Y); got != marked {
+			t.Errorf("orientation %d: pixel at %v = %v, want the marked one", tt.orientation, tt.wantMarked, got)
+		}
+	}
+}
+
+func TestThumbnails(t *testing.T) {
+	p := New(64, 64, []int{4, 8, 32})
+
+	img, err := p.Process(encodePNG(t, 16, 12))
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	thumbnails, err := p.Thumbnails(img)
+	if err != nil {
+		t.Fatalf("Thumbnails() error = %v", err)
+	}
+
+	// the center square is 12x12 and is never scaled up
+	want := map[int]int{4: 4, 8: 8, 32: 12}
+	for size, side := range want {
+		thumbnail, ok := thumbnails[size]
+		if !ok {
+			t.Errorf("thumbnail %d is missing", size)
+			continue
+		}
+		if thumbnail.Width != side || thumbnail.Height != side || thumbnail.ContentType != PNG {
+			t.Errorf("thumbnail %d = %dx%d %s, want %dx%d %s",
+				size, thumbnail.Width, thumbnail.Height, thumbnail.ContentType, side, side, PNG)
+		}
+	}
+}
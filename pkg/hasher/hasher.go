@@ -0,0 +1,242 @@
+package hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+// argon2 limits, the memory is in KiB.
+const (
+	maxArgon2Memory  = 1 << 20
+	minArgon2KeyLen  = 16
+	minArgon2SaltLen = 8
+)
+
+var (
+	ErrUnknownAlgorithm = errors.New("unknown hash algorithm")
+	ErrInvalidHash      = errors.New("invalid hash format")
+)
+
+type Argon2Params struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+type Hasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+}
+
+func New(algorithm string, argon2Params Argon2Params, bcryptCost int) (*Hasher, error) {
+	const op = "hasher.New"
+
+	switch algorithm {
+	case Argon2id:
+		if err := checkArgon2(argon2Params); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	case Bcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("%s: bcrypt cost %d is out of range", op, bcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrUnknownAlgorithm, algorithm)
+	}
+
+	return &Hasher{
+		algorithm:  algorithm,
+		argon2:     argon2Params,
+		bcryptCost: bcryptCost,
+	}, nil
+}
+
+// Hash returns the password hash encoded with the configured algorithm.
+// Argon2id hashes use the PHC string format, bcrypt hashes the modular crypt format.
+func (h *Hasher) Hash(password string) (string, error) {
+	const op = "hasher.Hash"
+
+	switch h.algorithm {
+	case Argon2id:
+		salt := make([]byte, h.argon2.SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		key := argon2.IDKey(
+			[]byte(password),
+			salt,
+			h.argon2.Time,
+			h.argon2.Memory,
+			h.argon2.Threads,
+			h.argon2.KeyLen,
+		)
+
+		return fmt.Sprintf(
+			"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version,
+			h.argon2.Memory,
+			h.argon2.Time,
+			h.argon2.Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key),
+		), nil
+	case Bcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		return string(hash), nil
+	default:
+		return "", fmt.Errorf("%s: %w", op, ErrUnknownAlgorithm)
+	}
+}
+
+// Compare reports whether password matches hash. Both argon2id and bcrypt
+// hashes are accepted regardless of the configured algorithm.
+func (h *Hasher) Compare(hash string, password string) (bool, error) {
+	const op = "hasher.Compare"
+
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, nil
+			}
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+
+		return true, nil
+	default:
+		return false, fmt.Errorf("%s: %w", op, ErrInvalidHash)
+	}
+}
+
+// NeedsRehash reports whether hash was produced with an algorithm or
+// parameters different from the current configuration.
+func (h *Hasher) NeedsRehash(hash string) bool {
+	switch h.algorithm {
+	case Argon2id:
+		if !strings.HasPrefix(hash, "$argon2id$") {
+			return true
+		}
+
+		params, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return true
+		}
+
+		return params.Time != h.argon2.Time ||
+			params.Memory != h.argon2.Memory ||
+			params.Threads != h.argon2.Threads ||
+			uint32(len(key)) != h.argon2.KeyLen ||
+			uint32(len(salt)) != h.argon2.SaltLen
+	case Bcrypt:
+		if !isBcrypt(hash) {
+			return true
+		}
+
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return true
+		}
+
+		return cost != h.bcryptCost
+	default:
+		return false
+	}
+}
+
+// checkArgon2 makes sure the configured parameters produce a usable hash:
+// zero time or threads panic in argon2 and an empty key matches any password.
+func checkArgon2(params Argon2Params) error {
+	if params.Time < 1 {
+		return errors.New("argon2 time must be positive")
+	}
+	if params.Threads < 1 {
+		return errors.New("argon2 threads must be positive")
+	}
+	if params.Memory < 8*uint32(params.Threads) || params.Memory > maxArgon2Memory {
+		return fmt.Errorf("argon2 memory must be between %d and %d KiB", 8*uint32(params.Threads), maxArgon2Memory)
+	}
+	if params.KeyLen < minArgon2KeyLen {
+		return fmt.Errorf("argon2 key length must be at least %d", minArgon2KeyLen)
+	}
+	if params.SaltLen < minArgon2SaltLen {
+		return fmt.Errorf("argon2 salt length must be at least %d", minArgon2SaltLen)
+	}
+
+	return nil
+}
+
+func decodeArgon2(hash string) (Argon2Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	if version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	var params Argon2Params
+	_, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+	// the stored parameters are trusted no more than the configured ones
+	if params.Time < 1 || params.Threads < 1 || params.Memory > maxArgon2Memory {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return Argon2Params{}, nil, nil, ErrInvalidHash
+	}
+
+	params.KeyLen = uint32(len(key))
+	params.SaltLen = uint32(len(salt))
+
+	return params, salt, key, nil
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") ||
+		strings.HasPrefix(hash, "$2b$") ||
+		strings.HasPrefix(hash, "$2y$")
+}
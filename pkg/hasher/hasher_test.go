@@ -0,0 +1,215 @@
+package hasher
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2 keeps the tests fast, it is far too weak for real use.
+var testArgon2 = Argon2Params{
+	Time:    1,
+	Memory:  64,
+	Threads: 1,
+	KeyLen:  16,
+	SaltLen: 8,
+}
+
+func newHasher(t *testing.T, algorithm string) *Hasher {
+	t.Helper()
+
+	h, err := New(algorithm, testArgon2, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("New(%s) error = %v", algorithm, err)
+	}
+
+	return h
+}
+
+func TestHashCompare(t *testing.T) {
+	for _, algorithm := range []string{Argon2id, Bcrypt} {
+		t.Run(algorithm, func(t *testing.T) {
+			h := newHasher(t, algorithm)
+
+			hash, err := h.Hash("correct horse")
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+
+			tests := []struct {
+				password string
+				want     bool
+			}{
+				{password: "correct horse", want: true},
+				{password: "correct horse ", want: false},
+				{password: "Correct horse", want: false},
+				{password: "", want: false},
+			}
+			for _, tt := range tests {
+				got, err := h.Compare(hash, tt.password)
+				if err != nil {
+					t.Fatalf("Compare(%q) error = %v", tt.password, err)
+				}
+				if got != tt.want {
+					t.Errorf("Compare(%q) = %t, want %t", tt.password, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestHashIsSalted(t *testing.T) {
+	h := newHasher(t, Argon2id)
+
+	first, err := h.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	second, err := h.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	if first == second {
+		t.Errorf("Hash() returned the same hash twice: %s", first)
+	}
+}
+
+func TestCompareOtherAlgorithm(t *testing.T) {
+	argon := newHasher(t, Argon2id)
+	bcryptHasher := newHasher(t, Bcrypt)
+
+	hash, err := argon.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	ok, err := bcryptHasher.Compare(hash, "password")
+	if err != nil || !ok {
+		t.Errorf("Compare() = %t, %v, want true, nil", ok, err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	argon := newHasher(t, Argon2id)
+	bcryptHasher := newHasher(t, Bcrypt)
+
+	argonHash, err := argon.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	bcryptHash, err := bcryptHasher.Hash("password")
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	stronger := testArgon2
+	stronger.Time = 2
+	strongerArgon, err := New(Argon2id, stronger, bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	costlierBcrypt, err := New(Bcrypt, testArgon2, bcrypt.MinCost+1)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher *Hasher
+		hash   string
+		want   bool
+	}{
+		{name: "same argon2 params", hasher: argon, hash: argonHash, want: false},
+		{name: "changed argon2 params", hasher: strongerArgon, hash: argonHash, want: true},
+		{name: "bcrypt to argon2", hasher: argon, hash: bcryptHash, want: true},
+		{name: "same bcrypt cost", hasher: bcryptHasher, hash: bcryptHash, want: false},
+		{name: "changed bcrypt cost", hasher: costlierBcrypt, hash: bcryptHash, want: true},
+		{name: "argon2 to bcrypt", hasher: bcryptHasher, hash: argonHash, want: true},
+		{name: "malformed argon2", hasher: argon, hash: "$argon2id$v=19$m=64", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareMalformed(t *testing.T) {
+	const (
+		salt = "c2FsdHNhbHQ"
+		key  = "MDEyMzQ1Njc4OWFiY2RlZg"
+	)
+
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{name: "empty", hash: ""},
+		{name: "plain text", hash: "password"},
+		{name: "dollar plain text", hash: "$password"},
+		{name: "missing key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt},
+		{name: "extra part", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key + "$x"},
+		{name: "other version", hash: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "bad params", hash: "$argon2id$v=19$m=x,t=1,p=1$" + salt + "$" + key},
+		{name: "zero time", hash: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{name: "zero threads", hash: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{name: "huge memory", hash: "$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key},
+		{name: "empty salt", hash: "$argon2id$v=19$m=64,t=1,p=1$$" + key},
+		{name: "empty key", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$"},
+		{name: "bad base64", hash: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$!!!"},
+	}
+
+	h := newHasher(t, Argon2id)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := h.Compare(tt.hash, "password")
+			if !errors.Is(err, ErrInvalidHash) {
+				t.Errorf("Compare() error = %v, want %v", err, ErrInvalidHash)
+			}
+			if ok {
+				t.Error("Compare() matched a malformed hash")
+			}
+		})
+	}
+}
+
+func TestNewRejectsUnsafeParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		change    func(p *Argon2Params)
+		cost      int
+	}{
+		{name: "unknown algorithm", algorithm: "md5"},
+		{name: "zero time", algorithm: Argon2id, change: func(p *Argon2Params) { p.Time = 0 }},
+		{name: "zero threads", algorithm: Argon2id, change: func(p *Argon2Params) { p.Threads = 0 }},
+		{name: "zero key length", algorithm: Argon2id, change: func(p *Argon2Params) { p.KeyLen = 0 }},
+		{name: "short salt", algorithm: Argon2id, change: func(p *Argon2Params) { p.SaltLen = 4 }},
+		{name: "too little memory", algorithm: Argon2id, change: func(p *Argon2Params) { p.Memory = 4 }},
+		{name: "too much memory", algorithm: Argon2id, change: func(p *Argon2Params) { p.Memory = maxArgon2Memory + 1 }},
+		{name: "low bcrypt cost", algorithm: Bcrypt, cost: bcrypt.MinCost - 1},
+		{name: "high bcrypt cost", algorithm: Bcrypt, cost: bcrypt.MaxCost + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testArgon2
+			if tt.change != nil {
+				tt.change(&params)
+			}
+			cost := tt.cost
+			if cost == 0 {
+				cost = bcrypt.MinCost
+			}
+
+			if _, err := New(tt.algorithm, params, cost); err == nil {
+				t.Error("New() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -98,3 +98,35 @@ func Interceptor(ctx context.Context) grpc.UnaryServerInterceptor {
 		return handler(lCtx, req)
 	}
 }
+
+func StreamInterceptor(ctx context.Context) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		log := FromCtx(ctx)
+		lCtx := context.WithValue(ss.Context(), Key, log)
+
+		md, ok := metadata.FromIncomingContext(lCtx)
+		if ok {
+			guid, ok := md[RequestID]
+			if ok {
+				lCtx = context.WithValue(lCtx, RequestID, guid[0])
+			}
+		}
+
+		FromCtx(lCtx).Info("stream",
+			zap.String("method", info.FullMethod),
+			zap.Time("request time", time.Now()),
+		)
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: lCtx})
+	}
+}
+
+// serverStream overrides the context of the wrapped stream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -4,16 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"io/fs"
 	"time"
 
 	"github.com/AlexMickh/proj-user/pkg/utils/retry"
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// New connects to the database and, when autoMigrate is set,
+// applies the pending migrations.
 func New(
 	ctx context.Context,
 	username string,
@@ -23,7 +25,8 @@ func New(
 	database string,
 	minPools int,
 	maxPools int,
-	migrationsPath string,
+	migrations fs.FS,
+	autoMigrate bool,
 ) (*pgxpool.Pool, error) {
 	const op = "postgres-client.New"
 
@@ -33,12 +36,8 @@ func New(
 		var err error
 
 		connString := fmt.Sprintf(
-			"postgres://%s:%s@%s:%d/%s?sslmode=disable&pool_max_conns=%d&pool_min_conns=%d",
-			username,
-			password,
-			host,
-			port,
-			database,
+			"%s&pool_max_conns=%d&pool_min_conns=%d",
+			dbUrl(username, password, host, port, database),
 			maxPools,
 			minPools,
 		)
@@ -53,13 +52,17 @@ func New(
 			return fmt.Errorf("%s: %w", op, err)
 		}
 
-		m, err := migrate.New(
-			"file://"+migrationsPath,
-			strings.Split(connString, "&")[0],
-		)
+		if !autoMigrate {
+			return nil
+		}
+
+		m, err := NewMigrate(username, password, host, port, database, migrations)
 		if err != nil {
 			return fmt.Errorf("%s: %w", op, err)
 		}
+		defer func() {
+			_, _ = m.Close()
+		}()
 
 		if err = m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
 			return fmt.Errorf("%s: %w", op, err)
@@ -73,3 +76,43 @@ func New(
 
 	return pool, nil
 }
+
+// NewMigrate returns a migrate instance for the migrations in the root of fsys,
+// it has to be closed by the caller.
+func NewMigrate(
+	username string,
+	password string,
+	host string,
+	port int,
+	database string,
+	migrations fs.FS,
+) (*migrate.Migrate, error) {
+	const op = "postgres-client.NewMigrate"
+
+	source, err := iofs.New(migrations, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := migrate.NewWithSourceInstance(
+		"iofs",
+		source,
+		dbUrl(username, password, host, port, database),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return m, nil
+}
+
+func dbUrl(username string, password string, host string, port int, database string) string {
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
+		username,
+		password,
+		host,
+		port,
+		database,
+	)
+}
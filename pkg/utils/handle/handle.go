@@ -0,0 +1,143 @@
+package handle
+
+import (
+	"errors"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 30
+)
+
+var (
+	ErrInvalid  = errors.New("handle may only contain latin letters, digits and single '_' or '-' between them")
+	ErrLength   = errors.New("handle must be 3 to 30 characters long")
+	ErrReserved = errors.New("handle is reserved")
+)
+
+var pattern = regexp.MustCompile(`^[a-z0-9]+([_-][a-z0-9]+)*$`)
+
+// reserved handles would clash with routes of the frontend or
+// could be mistaken for the staff.
+var reserved = map[string]struct{}{
+	"about":     {},
+	"account":   {},
+	"admin":     {},
+	"api":       {},
+	"auth":      {},
+	"billing":   {},
+	"help":      {},
+	"login":     {},
+	"logout":    {},
+	"me":        {},
+	"moderator": {},
+	"null":      {},
+	"profile":   {},
+	"register":  {},
+	"root":      {},
+	"search":    {},
+	"settings":  {},
+	"signin":    {},
+	"signup":    {},
+	"staff":     {},
+	"support":   {},
+	"system":    {},
+	"undefined": {},
+	"user":      {},
+	"users":     {},
+}
+
+// Normalize returns the canonical form of a handle: surrounding spaces and
+// a leading @ are trimmed and the handle is lowercased. Reserved handles are
+// valid, IsReserved tells them apart.
+func Normalize(handle string) (string, error) {
+	handle = strings.TrimPrefix(strings.TrimSpace(handle), "@")
+	handle = strings.ToLower(handle)
+
+	if len(handle) < MinLength || len(handle) > MaxLength {
+		return "", ErrLength
+	}
+	if !pattern.MatchString(handle) {
+		return "", ErrInvalid
+	}
+
+	return handle, nil
+}
+
+func IsReserved(handle string) bool {
+	_, ok := reserved[handle]
+	return ok
+}
+
+// FromLogin turns a provider login into a handle, characters that aren't
+// allowed are replaced with '_'. It returns an empty string when too little
+// of the login is left.
+func FromLogin(login string) string {
+	var b strings.Builder
+	separator := false
+	for _, r := range strings.ToLower(login) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			separator = false
+		case r == '-' && !separator:
+			b.WriteRune(r)
+			separator = true
+		case !separator:
+			b.WriteRune('_')
+			separator = true
+		}
+	}
+
+	handle := trim(b.String())
+	if len(handle) > MaxLength {
+		handle = trim(handle[:MaxLength])
+	}
+
+	handle, err := Normalize(handle)
+	if err != nil {
+		return ""
+	}
+
+	return handle
+}
+
+// Candidates returns n distinct handles derived from a valid base by adding
+// a numeric suffix, the base is shortened when the suffix doesn't fit.
+func Candidates(base string, n int) []string {
+	candidates := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for i := 0; len(candidates) < n && i < n*4; i++ {
+		// short suffixes first, they are the nicest to type
+		suffix := strconv.Itoa(i + 1)
+		if i >= 3 {
+			suffix = "_" + strconv.Itoa(100+rand.IntN(9900))
+		}
+
+		prefix := base
+		if len(prefix)+len(suffix) > MaxLength {
+			prefix = trim(prefix[:MaxLength-len(suffix)])
+		}
+
+		candidate := prefix + suffix
+		if _, ok := seen[candidate]; ok || IsReserved(candidate) {
+			continue
+		}
+		if _, err := Normalize(candidate); err != nil {
+			continue
+		}
+
+		seen[candidate] = struct{}{}
+		candidates = append(candidates, candidate)
+	}
+
+	return candidates
+}
+
+func trim(handle string) string {
+	return strings.Trim(handle, "_-")
+}
@@ -0,0 +1,48 @@
+package mailaddr
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// MaxLength is the longest address allowed by RFC 5321.
+const MaxLength = 254
+
+const maxLocalLength = 64
+
+var (
+	ErrInvalid = errors.New("email is invalid")
+	ErrTooLong = errors.New("email is too long")
+)
+
+// Normalize returns the canonical form of an email address: surrounding
+// spaces are trimmed, the address is lowercased and an internationalized
+// domain is converted to punycode.
+func Normalize(address string) (string, error) {
+	address = strings.TrimSpace(address)
+	if strings.ContainsFunc(address, unicode.IsSpace) {
+		return "", ErrInvalid
+	}
+
+	// quoted local parts with an @ are legal, but nobody uses them
+	local, domain, ok := strings.Cut(address, "@")
+	if !ok || local == "" || domain == "" || strings.Contains(domain, "@") {
+		return "", ErrInvalid
+	}
+
+	local = strings.ToLower(local)
+	domain, err := idna.Lookup.ToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil || domain == "" {
+		return "", ErrInvalid
+	}
+
+	normalized := local + "@" + domain
+	if len(local) > maxLocalLength || len(normalized) > MaxLength {
+		return "", ErrTooLong
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,72 @@
+package mailaddr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{name: "already normal", address: "alex@example.com", want: "alex@example.com"},
+		{name: "case folding", address: "Alex.Smith@Example.COM", want: "alex.smith@example.com"},
+		{name: "surrounding spaces", address: "  alex@example.com\n", want: "alex@example.com"},
+		{name: "trailing dot", address: "alex@example.com.", want: "alex@example.com"},
+		{name: "plus tag kept", address: "alex+news@example.com", want: "alex+news@example.com"},
+		{name: "idn domain", address: "user@bücher.de", want: "user@xn--bcher-kva.de"},
+		{name: "idn domain upper case", address: "User@BÜCHER.de", want: "user@xn--bcher-kva.de"},
+		{name: "punycode domain", address: "user@xn--bcher-kva.de", want: "user@xn--bcher-kva.de"},
+		{name: "longest local part", address: strings.Repeat("a", maxLocalLength) + "@example.com", want: strings.Repeat("a", maxLocalLength) + "@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.address)
+			if err != nil {
+				t.Fatalf("Normalize(%q) error = %v", tt.address, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeRejects(t *testing.T) {
+	// a 253 byte domain, the longest one dns allows
+	label := strings.Repeat("d", 63)
+	longDomain := strings.Join([]string{label, label, label, label[:57], "com"}, ".")
+
+	tests := []struct {
+		name    string
+		address string
+		wantErr error
+	}{
+		{name: "empty", address: "", wantErr: ErrInvalid},
+		{name: "spaces only", address: "   ", wantErr: ErrInvalid},
+		{name: "missing at", address: "alex.example.com", wantErr: ErrInvalid},
+		{name: "empty local part", address: "@example.com", wantErr: ErrInvalid},
+		{name: "empty domain", address: "alex@", wantErr: ErrInvalid},
+		{name: "double at", address: "a@@b.com", wantErr: ErrInvalid},
+		{name: "two ats", address: "a@b@c.com", wantErr: ErrInvalid},
+		{name: "inner space", address: "alex smith@example.com", wantErr: ErrInvalid},
+		{name: "inner tab", address: "alex@exa\tmple.com", wantErr: ErrInvalid},
+		{name: "bad domain", address: "alex@exa_mple.com", wantErr: ErrInvalid},
+		{name: "dot domain", address: "alex@.", wantErr: ErrInvalid},
+		{name: "long local part", address: strings.Repeat("a", maxLocalLength+1) + "@example.com", wantErr: ErrTooLong},
+		{name: "long address", address: "alex@" + longDomain, wantErr: ErrTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Normalize(tt.address)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Normalize(%q) = %q, %v, want %v", tt.address, got, err, tt.wantErr)
+			}
+		})
+	}
+}
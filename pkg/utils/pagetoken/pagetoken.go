@@ -0,0 +1,46 @@
+package pagetoken
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrInvalidToken = errors.New("invalid page token")
+
+// Encode serializes a cursor into an opaque url safe token.
+func Encode(cursor any) (string, error) {
+	const op = "pagetoken.Encode"
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// Decode restores a cursor produced by Encode. Tokens are not signed, so
+// anything but the exact fields of the cursor is rejected.
+func Decode(token string, cursor any) error {
+	const op = "pagetoken.Decode"
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cursor); err != nil {
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return nil
+}
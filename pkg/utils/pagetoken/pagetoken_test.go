@@ -0,0 +1,91 @@
+package pagetoken
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// cursor has the shape of the users listing cursor.
+type cursor struct {
+	Seed    string  `json:"s"`
+	Score   float64 `json:"sc,omitempty"`
+	SortKey string  `json:"k,omitempty"`
+	ID      string  `json:"i,omitempty"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor cursor
+	}{
+		{name: "empty", cursor: cursor{}},
+		{name: "seed only", cursor: cursor{Seed: "3f2a"}},
+		{name: "sort key", cursor: cursor{Seed: "3f2a", SortKey: "alex", ID: "0b9c1f4e-3b8a-4d6e-9a51-2f1c7d8e9a10"}},
+		{name: "score", cursor: cursor{Seed: "3f2a", Score: 0.734, ID: "0b9c1f4e-3b8a-4d6e-9a51-2f1c7d8e9a10"}},
+		{name: "unicode", cursor: cursor{Seed: "s", SortKey: "Алекс ✓", ID: "id"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Encode(tt.cursor)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if _, err := base64.RawURLEncoding.DecodeString(token); err != nil {
+				t.Fatalf("token %q is not url safe: %v", token, err)
+			}
+
+			var got cursor
+			if err := Decode(token, &got); err != nil {
+				t.Fatalf("Decode() error = %v", err)
+			}
+			if got != tt.cursor {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeTampered(t *testing.T) {
+	valid, err := Encode(cursor{Seed: "3f2a", SortKey: "alex", ID: "id"})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "empty", token: ""},
+		{name: "not base64", token: "!!!"},
+		{name: "padded base64", token: valid + "=="},
+		{name: "std base64", token: base64.StdEncoding.EncodeToString([]byte(`{"s":"?>?"}`))},
+		{name: "truncated", token: valid[:len(valid)-4]},
+		{name: "not json", token: encode("seed=3f2a")},
+		{name: "array", token: encode(`["3f2a"]`)},
+		{name: "wrong type", token: encode(`{"s":"3f2a","sc":"high"}`)},
+		{name: "unknown field", token: encode(`{"s":"3f2a","admin":true}`)},
+		{name: "trailing data", token: encode(`{"s":"3f2a"}{"s":"x"}`)},
+		{name: "trailing brace", token: encode(`{"s":"3f2a"}}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got cursor
+			if err := Decode(tt.token, &got); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Decode(%q) error = %v, want %v", tt.token, err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	if _, err := Encode(func() {}); err == nil {
+		t.Error("Encode() error = nil, want an error")
+	}
+}
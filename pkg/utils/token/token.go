@@ -0,0 +1,30 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const size = 32
+
+// Generate returns a random url safe token and the hash that should be stored instead of it.
+func Generate() (string, string, error) {
+	const op = "token.Generate"
+
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	return token, Hash(token), nil
+}
+
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}